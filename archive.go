@@ -0,0 +1,422 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/levmv/exisort/exifdate"
+)
+
+// isArchive reports whether the source is a ZIP or TAR file rather than a directory.
+func isArchive(src string) bool {
+	return archiveExt(src) != ""
+}
+
+// archiveExt returns the extension of an archive as written, e.g. ".tar.GZ", or "" for anything else.
+func archiveExt(src string) string {
+	name := strings.ToLower(src)
+	for _, ext := range []string{".zip", ".tar", ".tar.gz", ".tgz"} {
+		if strings.HasSuffix(name, ext) {
+			return src[len(src)-len(ext):]
+		}
+	}
+	return ""
+}
+
+// openArchive opens a ZIP or TAR file as a read-only file system.
+// Gzipped tarballs can't be read out of order, so they are unpacked to a temporary .tar first.
+func openArchive(src string) (fs.FS, io.Closer, error) {
+	name := strings.ToLower(src)
+	if strings.HasSuffix(name, ".zip") {
+		zr, err := zip.OpenReader(src)
+		if err != nil {
+			return nil, nil, err
+		}
+		return zr, zr, nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	temp := strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz")
+	if temp {
+		log.Info("Unpacking %s to a temporary file", src)
+		tmp, err := gunzipTemp(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		f = tmp
+	}
+
+	tfs, err := newTarFS(f, temp)
+	if err != nil {
+		f.Close()
+		if temp {
+			os.Remove(f.Name())
+		}
+		return nil, nil, err
+	}
+	return tfs, tfs, nil
+}
+
+// gunzipTemp decompresses r into a temporary file.
+func gunzipTemp(r io.Reader) (*os.File, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "exisort-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(tmp, zr); err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, err
+	}
+	return tmp, nil
+}
+
+// walkArchive is walkSource for the entries of an archive.
+func walkArchive(ctx context.Context, src string, fsys fs.FS, files chan<- sourceFile) {
+	feed := feeder{ctx: ctx, files: files}
+	fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Warn("Skipping %s/%s: %v", src, name, err)
+			return nil
+		}
+		if name == "." {
+			return nil
+		}
+		display := filepath.Join(src, filepath.FromSlash(name))
+
+		if excluded(name) {
+			log.Info("Skipping %s: excluded", display)
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if cfg.MaxDepth > 0 && strings.Count(name, "/")+1 >= cfg.MaxDepth {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !wantName(name, name) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			log.Warn("Skipping file info for %s: %v", display, err)
+			return nil
+		}
+		if wantFile(display, info) && !feed.send(sourceFile{Path: display, Info: info, Rel: name, FS: fsys, Name: name}) {
+			return fs.SkipAll
+		}
+		return nil
+	})
+}
+
+// scanEntry is scanFile for a file inside an archive.
+func scanEntry(sf sourceFile) (FileJob, bool) {
+	rs, done, err := openSeekable(sf.FS, sf.Name, sf.Info.Size())
+	if err != nil {
+		log.Warn("Skipping %s: %v", sf.Path, err)
+		return FileJob{}, false
+	}
+	defer done()
+
+	head := make([]byte, 64*1024)
+	n, err := io.ReadFull(rs, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		log.Warn("Failed to read header %s: %v", sf.Path, err)
+		return FileJob{}, false
+	}
+	validHead := head[:n]
+	if !wantContent(sf.Path, validHead) {
+		return FileJob{}, false
+	}
+
+	var ext string
+	if cfg.FixExt {
+		if ext = fixedExt(sf.Path, validHead); ext != "" {
+			log.Info("Wrong extension: %s is .%s", sf.Path, ext)
+		}
+	}
+
+	rs.Seek(0, io.SeekStart)
+	d := resolveEntry(sf, rs)
+	logDate(sf.Path, d)
+	if !wantDate(sf.Path, d.Time) || !wantScreenshot(sf.Path, d.Meta) || !filterHook.Want(sf.Path, d, sf.Info.Size()) {
+		return FileJob{}, false
+	}
+
+	motionOffset, _ := exifdate.MotionPhotoOffset(rs)
+	if motionOffset > 0 {
+		log.Info("Motion photo: %s", sf.Path)
+		if cfg.MotionPhoto == "strip" && int64(len(validHead)) > motionOffset {
+			validHead = validHead[:motionOffset]
+		}
+	}
+
+	stats.IncScanned()
+
+	job := FileJob{
+		Path:       sf.Path,
+		Rel:        sf.Rel,
+		Info:       sf.Info,
+		Date:       d.Time,
+		DateSource: d.Source,
+		Meta:       d.Meta,
+		SourceHead: validHead,
+		Hash:       computeFingerprint(validHead, sf.Info.Size()),
+		FS:         sf.FS,
+		Name:       sf.Name,
+
+		MotionOffset: motionOffset,
+		Ext:          ext,
+		Convert:      convertExt(sf.Name, ext),
+		Screenshot:   cfg.Screenshots == "separate" && isScreenshot(sf.Path, d.Meta),
+	}
+	geotag(&job)
+	return job, true
+}
+
+// resolveEntry is MetadataService.Resolve for archive entries.
+// ExifTool and XMP sidecars need real files, so only the native parser,
+// a Takeout sidecar in the same archive, the folder names (--path-dates) and the stored file time are used.
+func resolveEntry(sf sourceFile, rs io.ReadSeeker) DateInfo {
+	md, err := exifdate.ReadMetadata(rs)
+	d := resolveEntryDate(sf, md, err)
+	d.Meta = md
+	localizeVideo(sf.Name, &d)
+	shiftDate(&d)
+	return d
+}
+
+func resolveEntryDate(sf sourceFile, md exifdate.Metadata, err error) DateInfo {
+	if err == nil {
+		return DateInfo{Time: md.Date, Source: "native", Tag: md.DateTag, Raw: md.DateRaw}
+	}
+	for _, c := range takeoutCandidates(sf.Name, true) {
+		if data, err := fs.ReadFile(sf.FS, c); err == nil {
+			if d, found := parseTakeout(data, path.Base(c)); found {
+				return d
+			}
+		}
+	}
+	if cfg.PathDates {
+		if d, found := pathTime(sf.Name); found {
+			return d
+		}
+	}
+	return DateInfo{Time: sf.Info.ModTime(), Source: "mtime"}
+}
+
+// maxInMemoryEntry is the largest compressed entry unpacked into memory; bigger ones go to a temporary file.
+const maxInMemoryEntry = 32 << 20
+
+// openSeekable opens an archive entry for the date parsers, which need to seek.
+// The returned function releases it.
+func openSeekable(fsys fs.FS, name string, size int64) (io.ReadSeeker, func(), error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, func() { f.Close() }, nil
+	}
+	defer f.Close()
+
+	if size <= maxInMemoryEntry {
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bytes.NewReader(data), func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", "exisort-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	done := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+	if _, err := io.Copy(tmp, f); err != nil {
+		done()
+		return nil, nil, err
+	}
+	tmp.Seek(0, io.SeekStart)
+	return tmp, done, nil
+}
+
+// copyEntry writes the first job.size() bytes of an archive entry to dst.
+func copyEntry(job FileJob, dst string) error {
+	in, err := job.FS.Open(job.Name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := writeFile(dst, io.LimitReader(in, job.size()), nil); err != nil {
+		return err
+	}
+
+	os.Chtimes(dst, time.Now(), job.Info.ModTime())
+	return nil
+}
+
+// isEntryIdentical is areFilesDeepIdentical for an archive entry.
+func isEntryIdentical(job FileJob, existingPath string) bool {
+	f, err := job.FS.Open(job.Name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	h1, err := hashReader(f, job.size())
+	if err != nil {
+		return false
+	}
+	h2 := storedSum(existingPath)
+	if h2 == "" {
+		if h2, err = computeFullHash(existingPath, job.size()); err != nil {
+			return false
+		}
+	}
+	return h1 == h2
+}
+
+// tarFS serves the regular files of an uncompressed tarball straight from the file.
+type tarFS struct {
+	f       *os.File
+	temp    bool                 // f is an unpacked .tar.gz, removed on Close
+	entries map[string]*tarEntry // Files and directories by name; parents are added if the tarball omits them
+}
+
+type tarEntry struct {
+	info     fs.FileInfo
+	off      int64         // Start of the data in f
+	children []fs.DirEntry // For directories
+}
+
+func newTarFS(f *os.File, temp bool) (*tarFS, error) {
+	t := &tarFS{f: f, temp: temp, entries: map[string]*tarEntry{".": {info: tarDirInfo(".")}}}
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		if !fs.ValidPath(name) || name == "." || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, dup := t.entries[name]; dup {
+			continue // Appended again with tar -r; the first copy is as good as any
+		}
+		off, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		t.add(name, &tarEntry{info: hdr.FileInfo(), off: off})
+	}
+	for _, e := range t.entries {
+		slices.SortFunc(e.children, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	}
+	return t, nil
+}
+
+// add registers e and creates its parent directories as needed.
+func (t *tarFS) add(name string, e *tarEntry) {
+	t.entries[name] = e
+	for name != "." {
+		dir := path.Dir(name)
+		parent, ok := t.entries[dir]
+		if !ok {
+			parent = &tarEntry{info: tarDirInfo(path.Base(dir))}
+			t.entries[dir] = parent
+		}
+		parent.children = append(parent.children, fs.FileInfoToDirEntry(e.info))
+		if ok {
+			return
+		}
+		name, e = dir, parent
+	}
+}
+
+func (t *tarFS) Open(name string) (fs.File, error) {
+	e, ok := t.entries[name]
+	if !ok || !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	if e.info.IsDir() {
+		return &tarFile{info: e.info}, nil
+	}
+	return &tarFile{info: e.info, SectionReader: io.NewSectionReader(t.f, e.off, e.info.Size())}, nil
+}
+
+func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, ok := t.entries[name]
+	if !ok || !e.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return e.children, nil
+}
+
+func (t *tarFS) Close() error {
+	err := t.f.Close()
+	if t.temp {
+		os.Remove(t.f.Name())
+	}
+	return err
+}
+
+// tarFile is an open tarFS entry. Directories have no SectionReader.
+type tarFile struct {
+	*io.SectionReader
+	info fs.FileInfo
+}
+
+func (f *tarFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *tarFile) Close() error               { return nil }
+
+func (f *tarFile) Read(p []byte) (int, error) {
+	if f.SectionReader == nil {
+		return 0, &fs.PathError{Op: "read", Path: f.info.Name(), Err: fs.ErrInvalid}
+	}
+	return f.SectionReader.Read(p)
+}
+
+// tarDirInfo describes a directory of a tarball.
+type tarDirInfo string
+
+func (d tarDirInfo) Name() string       { return string(d) }
+func (d tarDirInfo) Size() int64        { return 0 }
+func (d tarDirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0555 }
+func (d tarDirInfo) ModTime() time.Time { return time.Time{} }
+func (d tarDirInfo) IsDir() bool        { return true }
+func (d tarDirInfo) Sys() any           { return nil }
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// groupBursts holds back the scanned files until scanning is done and numbers the bursts among them:
+// files that would get the same name from photos taken within the same second.
+// Members are numbered in order of their sub-second time, so the sequence follows the shutter.
+func groupBursts(in <-chan FileJob, dstRoot string) <-chan FileJob {
+	out := make(chan FileJob, 100)
+	go func() {
+		defer close(out)
+
+		type slot struct {
+			dest string
+			sec  int64
+		}
+		var order []slot
+		groups := make(map[slot][]FileJob)
+		for job := range in {
+			if job.Existing != "" {
+				out <- job // Not imported, and not dated either
+				continue
+			}
+			key := slot{destFor(dstRoot, job), job.Date.Unix()}
+			if _, ok := groups[key]; !ok {
+				order = append(order, key)
+			}
+			groups[key] = append(groups[key], job)
+		}
+
+		for _, key := range order {
+			group := groups[key]
+			if len(group) > 1 {
+				numberBurst(group)
+				log.Info("Burst of %d: %s", len(group), key.dest)
+			}
+			for _, job := range group {
+				out <- job
+			}
+		}
+	}()
+	return out
+}
+
+// numberBurst sets Burst on every member of a burst.
+// The same photo found twice in the source gets the same number, so the second copy is a duplicate.
+func numberBurst(group []FileJob) {
+	slices.SortStableFunc(group, func(a, b FileJob) int {
+		return cmp.Or(a.Date.Compare(b.Date), strings.Compare(a.Path, b.Path))
+	})
+	n := 0
+	for i := range group {
+		for _, prev := range group[:i] {
+			if prev.Hash == group[i].Hash && prev.size() == group[i].size() {
+				group[i].Burst = prev.Burst
+				break
+			}
+		}
+		if group[i].Burst == 0 {
+			n++
+			group[i].Burst = n
+		}
+	}
+}
+
+// burstPath adds the sequence number of a burst member to its destination,
+// "20240101_120000.jpg" becomes "20240101_120000_001.jpg" or, with --bursts folder,
+// "20240101_120000_burst/20240101_120000_001.jpg".
+func burstPath(dest string, n int) string {
+	ext := filepath.Ext(dest)
+	base := strings.TrimSuffix(dest, ext)
+	name := fmt.Sprintf("%s_%03d%s", filepath.Base(base), n, ext)
+	if cfg.Bursts == "folder" {
+		return filepath.Join(base+"_burst", name)
+	}
+	return filepath.Join(filepath.Dir(dest), name)
+}
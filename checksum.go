@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sumXattr holds "<hash> <mtime>" with --checksums xattr, e.g. user.exisort.sha256 for
+// the default --hash-algo. The modification time (in nanoseconds) tells whether the file
+// was changed after it was hashed.
+func sumXattr() string {
+	return "user.exisort." + cfg.HashAlgo
+}
+
+// sumExt is the extension of --checksums sidecar files, e.g. ".sha256", which sha256sum -c can check.
+func sumExt() string {
+	return "." + cfg.HashAlgo
+}
+
+// recordSum stores the full hash of a file written to the library, as --checksums asks.
+// sum is computed from the file if it isn't known yet.
+func recordSum(path, sum string, size int64) {
+	if cfg.Checksums == "" || cfg.Symlink || cfg.DryRun {
+		return
+	}
+	if sum == "" {
+		var err error
+		if sum, err = computeFullHash(path, size); err != nil {
+			log.Warn("Failed to hash %s: %v", path, err)
+			return
+		}
+	}
+
+	var err error
+	switch cfg.Checksums {
+	case "xattr":
+		var info os.FileInfo
+		if info, err = os.Stat(path); err == nil {
+			err = setXattr(path, sumXattr(), fmt.Appendf(nil, "%s %d", sum, info.ModTime().UnixNano()))
+		}
+	case "sidecar":
+		err = os.WriteFile(path+sumExt(), fmt.Appendf(nil, "%s  %s\n", sum, filepath.Base(path)), 0644)
+		if err == nil {
+			noteWritten(path + sumExt())
+		}
+	}
+	if err != nil {
+		log.Warn("Failed to store the checksum of %s: %v", path, err)
+	}
+}
+
+// storedSum returns the hash recorded for path by --checksums, or "" if there is none
+// or the file was modified after it was recorded.
+func storedSum(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+
+	if value, err := getXattr(path, sumXattr()); err == nil {
+		sum, mtime, _ := strings.Cut(string(value), " ")
+		if ns, err := strconv.ParseInt(mtime, 10, 64); err == nil && ns == info.ModTime().UnixNano() {
+			return sum
+		}
+	}
+
+	sidecar, err := os.Stat(path + sumExt())
+	if err != nil || sidecar.ModTime().Before(info.ModTime()) {
+		return ""
+	}
+	data, err := os.ReadFile(path + sumExt())
+	if err != nil {
+		return ""
+	}
+	sum, name, _ := strings.Cut(strings.TrimSpace(string(data)), "  ")
+	if name != filepath.Base(path) {
+		return ""
+	}
+	return sum
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// cleanOptions are the flags of the clean command.
+type cleanOptions struct {
+	Reference string
+	Action    string // trash, delete, reflink
+}
+
+// runClean removes the files of a folder, e.g. an old backup, that the reference library has an identical copy of:
+//
+//	exisort clean <target> -reference <library> [-action trash|delete|reflink]
+func runClean(ctx context.Context, args []string) error {
+	var opts cleanOptions
+	fset := flag.NewFlagSet("clean", flag.ExitOnError)
+	fset.StringVar(&opts.Reference, "reference", "", "Library whose files may be removed from the target")
+	fset.StringVar(&opts.Action, "action", "trash", "What to do with files found in the reference: trash (move to "+trashDir+" in the target), delete, "+
+		"or reflink (keep them as copy-on-write clones of the reference, on the same Btrfs, XFS or APFS file system)")
+
+	// The flags may come before or after the target
+	fset.Parse(args)
+	var target string
+	if fset.NArg() > 0 {
+		target = fset.Arg(0)
+		fset.Parse(fset.Args()[1:])
+	}
+	switch {
+	case target == "" || fset.NArg() > 0 || opts.Reference == "":
+		return errors.New("usage: exisort clean <target> -reference <library> [-action trash|delete|reflink]")
+	case opts.Action != "trash" && opts.Action != "delete" && opts.Action != "reflink":
+		return errors.New("-action must be trash, delete or reflink")
+	}
+
+	t, err := realPath(target)
+	if err != nil {
+		return err
+	}
+	ref, err := realPath(opts.Reference)
+	if err != nil {
+		return err
+	}
+	if isWithin(t, ref) || isWithin(ref, t) {
+		return errors.New("the target and the reference must not contain each other")
+	}
+
+	log.Status("Reading the reference library...")
+	lib, err := indexBySize(ctx, ref)
+	if err != nil {
+		return err
+	}
+	return cleanTree(ctx, t, lib, opts)
+}
+
+// isWithin reports whether path is dir or inside it.
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// refLibrary finds identical copies in the reference library. Files are only hashed once a file
+// of the same size turns up in the target.
+type refLibrary struct {
+	bySize map[int64][]string
+	sums   map[string]string
+}
+
+// walkFiles calls fn for the regular files of root that --include, --exclude and --skip-system
+// let through, like the source of an import. fn can stop the walk with filepath.SkipAll or an error.
+// exisort's own folders are never looked into.
+func walkFiles(ctx context.Context, root string, fn func(path string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Warn("Skipping path %s: %v", path, err)
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+		if d.IsDir() && (d.Name() == metaDir || d.Name() == trashDir) {
+			return filepath.SkipDir
+		}
+		if excluded(rel) {
+			log.Info("Skipping %s: excluded", path)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || len(cfg.Include) > 0 && !matchAny(cfg.Include, rel) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			log.Warn("Skipping file info for %s: %v", path, err)
+			return nil
+		}
+		return fn(path, info)
+	})
+}
+
+// indexBySize lists the files of root by size.
+func indexBySize(ctx context.Context, root string) (*refLibrary, error) {
+	lib := &refLibrary{bySize: make(map[int64][]string), sums: make(map[string]string)}
+	err := walkFiles(ctx, root, func(path string, info fs.FileInfo) error {
+		lib.bySize[info.Size()] = append(lib.bySize[info.Size()], path)
+		return nil
+	})
+	return lib, err
+}
+
+// find returns a file of the library with the content sum, if there is one.
+func (lib *refLibrary) find(sum string, size int64) string {
+	for _, path := range lib.bySize[size] {
+		s, ok := lib.sums[path]
+		if !ok {
+			if s = storedSum(path); s == "" {
+				var err error
+				if s, err = computeFullHash(path, size); err != nil {
+					log.Warn("Failed to hash %s: %v", path, err)
+				}
+			}
+			lib.sums[path] = s
+		}
+		if s == sum {
+			return path
+		}
+	}
+	return ""
+}
+
+// cleanTree removes the files of root that lib has a copy of. --limit counts the files of root
+// that pass the filters.
+func cleanTree(ctx context.Context, root string, lib *refLibrary, opts cleanOptions) error {
+	var listed, scanned, removed, freed, errs int64
+	trash := newTrash(root)
+	defer trash.Close()
+	label := "Removed"
+	if opts.Action == "reflink" {
+		label = "Cloned"
+	}
+	err := walkFiles(ctx, root, func(path string, info fs.FileInfo) error {
+		if cfg.Limit > 0 && listed >= int64(cfg.Limit) {
+			log.Info("Stopping after %d files (--limit)", listed)
+			return filepath.SkipAll
+		}
+		listed++
+		if len(lib.bySize[info.Size()]) == 0 {
+			return nil
+		}
+		scanned++
+		log.Status("Checked: %d | %s: %d | %s", scanned, label, removed, path)
+
+		sum, err := computeFullHash(path, info.Size())
+		if err != nil {
+			errs++
+			log.Error("Failed to hash %s: %v", path, err)
+			return nil
+		}
+		copyPath := lib.find(sum, info.Size())
+		if copyPath == "" {
+			log.Info("Keeping %s: not in the reference", path)
+			return nil
+		}
+
+		if !cfg.DryRun {
+			if err := cleanFile(trash, path, copyPath, info, opts.Action); err != nil {
+				if errors.Is(err, errNoClone) {
+					return err
+				}
+				errs++
+				log.Error("Failed to %s %s: %v", opts.Action, path, err)
+				return nil
+			}
+		}
+		log.Clean(opts.Action, path, copyPath)
+		removed++
+		freed += info.Size()
+		return nil
+	})
+	log.ClearStatus()
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(os.Stderr, "----------------------------------------")
+	fmt.Fprintf(w, "Checked:\t%d\n", scanned)
+	fmt.Fprintf(w, "%s:\t%d (%s)\n", label, removed, formatBytes(freed))
+	if errs > 0 {
+		fmt.Fprintf(w, "Errors:\t%d\n", errs)
+	}
+	w.Flush()
+	fmt.Fprintln(os.Stderr, "----------------------------------------")
+
+	if err == nil && errs > 0 {
+		err = fmt.Errorf("%d files could not be cleaned", errs)
+	}
+	return err
+}
+
+// cleanFile deletes path, moves it into the trash, or replaces it with a clone of copyPath.
+func cleanFile(trash *Trash, path, copyPath string, info fs.FileInfo, action string) error {
+	switch action {
+	case "delete":
+		return os.Remove(path)
+	case "reflink":
+		return reflinkFile(copyPath, path, info)
+	}
+	return trash.Move(path)
+}
+
+// errNoClone stops a clean with -action reflink: if one clone can't be made, none can.
+var errNoClone = errors.New("-action reflink needs the target and the reference on the same Btrfs, XFS or APFS file system")
+
+// reflinkFile replaces path with a copy-on-write clone of copyPath, an identical file, so that both
+// share their data blocks. The clone gets the permissions and modification time of the file it replaces.
+func reflinkFile(copyPath, path string, info fs.FileInfo) error {
+	part := path + partSuffix
+	os.Remove(part) // From an earlier clean that was interrupted
+	if err := cloneFile(copyPath, part); err != nil {
+		if errors.Is(err, fs.ErrPermission) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errNoClone, err)
+	}
+	if cfg.Verify {
+		same, err := areFilesDeepIdentical(part, path, info.Size())
+		if err == nil && !same {
+			err = errVerifyFailed
+		}
+		if err != nil {
+			os.Remove(part)
+			return err
+		}
+	}
+	if err := os.Chmod(part, info.Mode().Perm()); err != nil {
+		os.Remove(part)
+		return err
+	}
+	os.Chtimes(part, time.Now(), info.ModTime())
+	return os.Rename(part, path)
+}
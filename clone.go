@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sync/atomic"
+)
+
+// cloneOff is set once cloning failed for a reason other than an existing destination,
+// so the remaining files go straight to the regular copy.
+var cloneOff atomic.Bool
+
+// tryClone makes dst a copy-on-write clone of src (Btrfs, XFS, APFS...).
+// Cloning is instant and takes no extra space until one of the files changes.
+// It reports false if the data has to be copied instead.
+func tryClone(src, dst string) bool {
+	if !cfg.Reflink || cloneOff.Load() {
+		return false
+	}
+	if err := cloneFile(src, dst); err != nil {
+		if !errors.Is(err, fs.ErrExist) && !cloneOff.Swap(true) {
+			log.Info("Copy-on-write clones not available (%v), copying data", err)
+		}
+		return false
+	}
+	return true
+}
+
+// verifyClone checks a clone like copyData checks a copy when --verify is set.
+func verifyClone(src, dst string, size int64) error {
+	if !cfg.Verify {
+		return nil
+	}
+	same, err := areFilesDeepIdentical(src, dst, size)
+	if err == nil && !same {
+		err = errVerifyFailed
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
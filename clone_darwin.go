@@ -0,0 +1,9 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+// cloneFile creates dst sharing the data blocks of src (APFS clonefile).
+// It fails if dst exists or src and dst are on different volumes.
+func cloneFile(src, dst string) error {
+	return unix.Clonefile(src, dst, unix.CLONE_NOFOLLOW)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+func cloneFile(src, dst string) error {
+	return errors.ErrUnsupported
+}
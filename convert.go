@@ -0,0 +1,134 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// heicExts are the extensions --convert heic=jpg applies to. HEIC sequences (.heics) are left alone.
+var heicExts = []string{"heic", "heif", "hif"}
+
+// heicConverters are the programs tried for --convert heic=jpg, in this order.
+// {src} and {dst} stand for the input and output files.
+var heicConverters = [][]string{
+	{"heif-convert", "-q", "92", "{src}", "{dst}"},                                           // libheif
+	{"magick", "{src}", "-quality", "92", "{dst}"},                                           // ImageMagick 7
+	{"sips", "-s", "format", "jpeg", "-s", "formatOptions", "92", "{src}", "--out", "{dst}"}, // macOS
+}
+
+// heicConverter is the first of heicConverters that is installed, nil if there is none.
+var heicConverter = sync.OnceValue(func() []string {
+	for _, c := range heicConverters {
+		if _, err := exec.LookPath(c[0]); err == nil {
+			return c
+		}
+	}
+	return nil
+})
+
+// convertExt returns the extension a file named path is converted to with --convert, or "" if it is imported as is.
+// fixed is its extension from --fix-ext, if any. Upper case names stay upper case.
+func convertExt(path, fixed string) string {
+	if !cfg.ConvertHEIC {
+		return ""
+	}
+	ext := fixed
+	if ext == "" {
+		ext = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	if !slices.Contains(heicExts, strings.ToLower(ext)) {
+		return ""
+	}
+	if ext == strings.ToUpper(ext) {
+		return "JPG"
+	}
+	return "jpg"
+}
+
+// convertJob writes job to destPath as a JPEG. With --keep-original the original is written next to it,
+// under its own extension. The source is left alone; moving it away is up to the caller.
+func convertJob(job FileJob, destPath string) error {
+	// The converter needs a plain file: the source itself, the kept original or a temporary copy of an archive entry
+	src := job.Path
+	if cfg.KeepOriginal {
+		ext := job.Ext
+		if ext == "" {
+			ext = strings.TrimPrefix(filepath.Ext(job.Path), ".")
+		}
+		src = strings.TrimSuffix(destPath, filepath.Ext(destPath)) + "." + ext
+		if destExists(src) && !isFileIdentical(job, src) {
+			return fmt.Errorf("%s already exists", src)
+		}
+		if err := copySource(job, src); err != nil {
+			return err
+		}
+		noteWritten(src)
+	} else if job.FS != nil {
+		src = destPath + ".tmp" + filepath.Ext(job.Name)
+		if err := copySource(job, src); err != nil {
+			return err
+		}
+		defer os.Remove(src)
+	}
+
+	if err := convertImage(src, destPath); err != nil {
+		return err
+	}
+	if cfg.MotionPhoto == "extract" && job.MotionOffset > 0 {
+		extractMotionVideo(job, src, destPath)
+	}
+	writeDate(job, destPath)
+	os.Chtimes(destPath, time.Now(), job.Info.ModTime())
+	return nil
+}
+
+// convertImage converts src into the JPEG dst with heicConverter. The output is written to a folder
+// of its own first, since some converters also save the depth map and other images of a HEIC next to it.
+func convertImage(src, dst string) error {
+	conv := heicConverter()
+	if conv == nil {
+		return errors.New("no HEIC converter found (heif-convert, magick or sips)")
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), ".exisort-convert-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	out := filepath.Join(tmp, "out.jpg")
+	args := make([]string, len(conv)-1)
+	for i, a := range conv[1:] {
+		args[i] = strings.NewReplacer("{src}", src, "{dst}", out).Replace(a)
+	}
+	if msg, err := exec.Command(conv[0], args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", conv[0], err, strings.TrimSpace(string(msg)))
+	}
+	return os.Rename(out, dst)
+}
+
+// writeDate stores the date of job in the converted file at path if it didn't come from the metadata of the file,
+// e.g. from its path or a Takeout sidecar, which the JPEG would otherwise lose. The EXIF the converter copies
+// already has the others.
+func writeDate(job FileJob, path string) {
+	switch job.DateSource {
+	case "native", "exiftool", "mtime":
+		return
+	}
+	if exifWriter == nil {
+		return
+	}
+	tags := map[string]string{"DateTimeOriginal": job.Date.Format("2006:01:02 15:04:05")}
+	if job.Date.Location() != time.Local {
+		tags["OffsetTimeOriginal"] = job.Date.Format("-07:00")
+	}
+	if _, err := exifWriter.WriteTags(path, tags); err != nil {
+		log.Warn("Failed to write the date of %s: %v", path, err)
+	}
+}
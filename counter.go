@@ -0,0 +1,50 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// counters holds the last {counter} number handed out in each --counter-reset scope.
+var counters = struct {
+	sync.Mutex
+	m map[string]int
+}{m: make(map[string]int)}
+
+func usesCounter() bool {
+	return formatUses("counter")
+}
+
+// counterScope is the key of the sequence job is numbered in: one for the run, per destination folder or per day.
+func counterScope(dstRoot string, job FileJob) string {
+	switch cfg.CounterReset {
+	case "dir":
+		return filepath.Dir(destFor(dstRoot, job))
+	case "day":
+		date := job.Date
+		if cfg.Zone == "local" {
+			date = date.In(time.Local)
+		}
+		return date.Format(time.DateOnly)
+	}
+	return ""
+}
+
+// numberJob gives job the next {counter} number of its scope and returns its destination.
+// Numbers taken by other files, e.g. from an earlier run, are passed over; a file that is already there
+// under its number keeps it and is found to be a duplicate.
+func numberJob(dstRoot string, job *FileJob) string {
+	scope := counterScope(dstRoot, *job)
+	for {
+		counters.Lock()
+		counters.m[scope]++
+		job.Counter = counters.m[scope]
+		counters.Unlock()
+
+		dest := destFor(dstRoot, *job)
+		if !destExists(dest) || isFileIdentical(*job, dest) {
+			return dest
+		}
+	}
+}
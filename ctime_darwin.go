@@ -0,0 +1,18 @@
+package main
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// changeTime is the later of the modification and the inode change time, see ctime_linux.go.
+func changeTime(info fs.FileInfo) time.Time {
+	t := info.ModTime()
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		if c := time.Unix(st.Ctimespec.Unix()); c.After(t) {
+			return c
+		}
+	}
+	return t
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// changeTime is the later of the modification and the inode change time. Files moved or
+// copied with their old dates into the source still get a fresh change time.
+func changeTime(info fs.FileInfo) time.Time {
+	t := info.ModTime()
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		if c := time.Unix(st.Ctim.Unix()); c.After(t) {
+			return c
+		}
+	}
+	return t
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package main
+
+import (
+	"io/fs"
+	"time"
+)
+
+// changeTime falls back to the modification time where the change time isn't available.
+func changeTime(info fs.FileInfo) time.Time {
+	return info.ModTime()
+}
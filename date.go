@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// runDate prints the detected date of each file and where it came from.
+// Handy to find out why a photo ended up in the wrong folder.
+func runDate(metaSvc *MetadataService, paths []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "FILE\tDATE\tSOURCE\tTAG\tRAW")
+
+	var failed int
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Error("%v", err)
+			failed++
+			continue
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			log.Error("%v", err)
+			failed++
+			continue
+		}
+
+		d := metaSvc.Resolve(f, info)
+		f.Close()
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", path, d.Time.Format("2006-01-02 15:04:05.000 -07:00"), d.Source, d.Tag, d.Raw)
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files could not be read", failed, len(paths))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// destDirs caches the names in each destination directory, in lower case, so that finding a free
+// name costs one directory read per folder instead of a stat per candidate, which is slow on
+// network shares. Names exisort writes are added with noteWritten.
+var destDirs = struct {
+	sync.Mutex
+	m map[string]map[string]bool
+}{m: make(map[string]map[string]bool)}
+
+// destExists reports whether path exists in the destination. Only a name in the cache is
+// confirmed with a stat, which also settles case differences for the file system.
+func destExists(path string) bool {
+	if _, ok := plan.claimant(path); ok {
+		return true
+	}
+	dir, name := filepath.Split(path)
+	dir = filepath.Clean(dir)
+
+	destDirs.Lock()
+	names, ok := destDirs.m[dir]
+	if !ok {
+		names = make(map[string]bool)
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			names[strings.ToLower(e.Name())] = true
+		}
+		destDirs.m[dir] = names
+	}
+	known := names[strings.ToLower(name)]
+	destDirs.Unlock()
+
+	if !known {
+		return false
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// noteWritten adds a file written into the destination to the cache.
+func noteWritten(path string) {
+	dir, name := filepath.Split(path)
+	destDirs.Lock()
+	defer destDirs.Unlock()
+	if names, ok := destDirs.m[filepath.Clean(dir)]; ok {
+		names[strings.ToLower(name)] = true
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// errDiskFull stops an import before the destination runs out of space.
+var errDiskFull = errors.New("destination is almost full")
+
+// existingParent returns path or its nearest existing parent, for checking a destination not created yet.
+func existingParent(path string) string {
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return path
+		}
+		path = parent
+	}
+}
+
+// spaceChecked tells whether free space is watched for this run: not if nothing is written
+// or if moved files are only renamed.
+func spaceChecked(srcRoot, dstRoot string) bool {
+	return cfg.MinFree > 0 && !cfg.DryRun && !cfg.Symlink && !(cfg.Move && srcRoot != "" && sameVolume(srcRoot, dstRoot))
+}
+
+// listAll lists the whole source for the preflight. The import then works from the list rather than
+// walking the source again.
+func listAll(ctx context.Context, srcFS fs.FS, srcRoot, dstRoot string) []sourceFile {
+	files := make(chan sourceFile, 100)
+	go func() {
+		defer close(files)
+		listSource(ctx, srcFS, srcRoot, dstRoot, files)
+	}()
+	var list []sourceFile
+	for sf := range files {
+		list = append(list, sf)
+	}
+	return list
+}
+
+// checkSpace is the preflight: it warns if the listed files don't fit on the destination, keeping
+// --min-free to spare. Duplicates already in the library are counted too, so it can warn about an
+// import that fits; running out of space is only prevented by the check made before each file.
+func checkSpace(list []sourceFile, dstRoot string) {
+	var need int64
+	for _, sf := range list {
+		need += sf.Info.Size()
+	}
+	for _, root := range []string{dstRoot, cfg.Dest2} {
+		if root == "" {
+			continue
+		}
+		free, err := freeSpace(root)
+		if err != nil {
+			log.Info("Can't check free space on %s: %v", root, err)
+			continue
+		}
+		if uint64(need+cfg.MinFree) > free {
+			log.Warn("The source has %s to import, but only %s is free on %s (keeping %s with --min-free). "+
+				"Files already in the library count too; the import goes on and stops if space runs out", formatBytes(need), formatBytes(int64(free)), root, formatBytes(cfg.MinFree))
+		}
+	}
+}
+
+// haveSpace reports whether size more bytes fit on root, keeping --min-free to spare.
+// File systems that can't tell are assumed to have enough.
+func haveSpace(root string, size int64) bool {
+	if root == "" {
+		return true
+	}
+	free, err := freeSpace(root)
+	return err != nil || uint64(size+cfg.MinFree) <= free
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+func freeSpace(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(existingParent(path))
+	if err != nil {
+		return 0, err
+	}
+	var free uint64
+	err = windows.GetDiskFreeSpaceEx(p, &free, nil, nil)
+	return free, err
+}
+
+func sameVolume(a, b string) bool {
+	va := filepath.VolumeName(absPath(a))
+	return va != "" && strings.EqualFold(va, filepath.VolumeName(absPath(b)))
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/levmv/exisort/exifdate"
+)
+
+// editorSoftware are the photo editors whose name in the Software tag marks an edited export (lower case).
+// Cameras and phones write their firmware version there, which doesn't count.
+var editorSoftware = []string{
+	"lightroom", "photoshop", "snapseed", "capture one", "darktable", "rawtherapee", "gimp", "affinity",
+	"luminar", "pixelmator", "vsco", "picasa", "dxo", "acdsee", "on1 photo", "facetune", "google photos",
+}
+
+// isEdited reports whether the Software tag of md names a photo editor.
+func isEdited(md exifdate.Metadata) bool {
+	software := strings.ToLower(md.Software)
+	for _, s := range editorSoftware {
+		if strings.Contains(software, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// preferOriginals holds back all jobs until scanning is complete, then drops the edited exports of photos
+// whose original is in the source as well: taken with the same camera in the same second (--prefer-originals).
+func preferOriginals(in <-chan FileJob) <-chan FileJob {
+	out := make(chan FileJob, 100)
+	go func() {
+		defer close(out)
+
+		type shot struct {
+			model string
+			sec   int64
+		}
+		var all []FileJob
+		originals := make(map[shot]string)
+		for job := range in {
+			all = append(all, job)
+			if job.Existing == "" && job.DateSource != "mtime" && !isEdited(job.Meta) {
+				originals[shot{job.Meta.Model, job.Date.Unix()}] = job.Path
+			}
+		}
+
+		for _, job := range all {
+			if job.Existing == "" && job.DateSource != "mtime" && isEdited(job.Meta) {
+				if orig, ok := originals[shot{job.Meta.Model, job.Date.Unix()}]; ok {
+					log.Info("Skipping %s: edited with %s, the original is %s", job.Path, job.Meta.Software, orig)
+					continue
+				}
+			}
+			out <- job
+		}
+	}()
+	return out
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"strconv"
+)
+
+// stripGPSTags are what --strip-gps deletes: the EXIF GPS block, XMP positions and the positions of QuickTime videos.
+var stripGPSTags = []string{"GPS:All", "XMP:Geotag", "Keys:GPSCoordinates", "UserData:GPSCoordinates"}
+
+// exifWriter edits the imported files for --gpx-write, --strip-gps and --strip-tags.
+var exifWriter *MetadataService
+
+// editsCopies reports whether imported files are changed after they are written.
+func editsCopies() bool {
+	return cfg.GPXWrite || len(cfg.StripTags) > 0
+}
+
+// editCopy writes the track position of job into its copy at path and deletes the tags to strip,
+// and reports whether the file changed. The modification time is kept, since ExifTool updates it.
+func editCopy(job FileJob, path string) bool {
+	if !editsCopies() || exifWriter == nil {
+		return false
+	}
+	tags := make(map[string]string)
+	for _, t := range cfg.StripTags {
+		tags[t] = ""
+	}
+	if cfg.GPXWrite && job.Geotagged {
+		tags["GPSLatitude"], tags["GPSLatitudeRef"] = gpsRef(job.Meta.Latitude, "N", "S")
+		tags["GPSLongitude"], tags["GPSLongitudeRef"] = gpsRef(job.Meta.Longitude, "E", "W")
+	}
+	if len(tags) == 0 {
+		return false
+	}
+
+	changed, err := exifWriter.WriteTags(path, tags)
+	if err != nil {
+		log.Warn("Failed to edit the metadata of %s: %v", path, err)
+		return false
+	}
+	if changed {
+		mtime := job.Info.ModTime()
+		os.Chtimes(path, mtime, mtime)
+	}
+	return changed
+}
+
+// gpsRef splits a signed coordinate into the value and hemisphere ExifTool writes.
+func gpsRef(v float64, pos, neg string) (string, string) {
+	ref := pos
+	if v < 0 {
+		v, ref = -v, neg
+	}
+	return strconv.FormatFloat(v, 'f', 7, 64), ref
+}
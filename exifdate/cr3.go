@@ -0,0 +1,72 @@
+package exifdate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Canon stores the metadata boxes (CMT1..CMT4) inside this 'moov/uuid' box.
+var canonUUID = []byte{0x85, 0xc0, 0xb6, 0x87, 0x82, 0x0f, 0x11, 0xe0, 0x81, 0x11, 0xf4, 0xce, 0x46, 0x2b, 0x6a, 0x48}
+
+func isCR3(sig []byte) bool {
+	return string(sig[4:8]) == "ftyp" && string(sig[8:12]) == "crx "
+}
+
+// ExtractExifFromCR3 returns a TIFF blob from a Canon CR3 file.
+// CMT1 holds IFD0 (only the modify date), CMT2 holds the Exif IFD with DateTimeOriginal,
+// each as a standalone TIFF structure. We prefer CMT2 and fall back to CMT1.
+func ExtractExifFromCR3(r io.ReadSeeker) ([]byte, error) {
+	moov, err := findBox(r, 0, ^uint64(0), "moov")
+	if err != nil {
+		return nil, fmt.Errorf("%w: moov box not found: %v", ErrUnsupported, err)
+	}
+
+	var canon boxHeader
+	found := false
+	err = scanBoxes(r, moov.dataOffset, moov.dataOffset+moov.dataSize, func(b boxHeader) (bool, error) {
+		if b.typ != "uuid" || b.dataSize < 16 {
+			return false, nil
+		}
+		var userType [16]byte
+		if _, err := io.ReadFull(r, userType[:]); err != nil {
+			return false, err
+		}
+		if bytes.Equal(userType[:], canonUUID) {
+			canon = b
+			found = true
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: canon uuid box not found", ErrUnsupported)
+	}
+
+	// Children start right after the 16-byte user type
+	start := canon.dataOffset + 16
+	end := canon.dataOffset + canon.dataSize
+
+	for _, typ := range []string{"CMT2", "CMT1"} {
+		cmt, err := findBox(r, start, end, typ)
+		if err != nil {
+			continue
+		}
+		if cmt.dataSize > maxTIFFRead {
+			return nil, fmt.Errorf("%w: %s box too large", ErrUnsupported, typ)
+		}
+		if _, err := r.Seek(int64(cmt.dataOffset), io.SeekStart); err != nil {
+			return nil, err
+		}
+		data := make([]byte, cmt.dataSize)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("%w: no CMT boxes found", ErrUnsupported)
+}
@@ -0,0 +1,57 @@
+package exifdate
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Detect identifies the format of a file from its first bytes (64 are enough) and
+// returns the usual extension for it without the dot, e.g. "jpg", "heic" or "mov".
+// Plain TIFF based RAW formats (NEF, ARW, DNG) can't be told apart and are reported as "tif".
+// It returns "" for anything the package doesn't know.
+func Detect(head []byte) string {
+	if len(head) < 12 {
+		return ""
+	}
+	switch {
+	case isJPEG(head):
+		return "jpg"
+	case isPNG(head):
+		return "png"
+	case isCR3(head):
+		return "cr3"
+	case isHEIC(head):
+		end := min(max(int(binary.BigEndian.Uint32(head[0:4])), 12), len(head))
+		if bytes.Contains(head[8:end], []byte("avif")) { // Major or compatible brand
+			return "avif"
+		}
+		return "heic"
+	case isJXL(head):
+		return "jxl"
+	case isAVI(head):
+		return "avi"
+	case isMatroska(head):
+		if bytes.Contains(head, []byte("webm")) { // EBML DocType
+			return "webm"
+		}
+		return "mkv"
+	case isQuickTime(head):
+		if string(head[4:8]) == "ftyp" && string(head[8:12]) != "qt  " {
+			return "mp4"
+		}
+		return "mov"
+	case isTIFFBased(head):
+		order, _ := tiffByteOrder(head)
+		switch order.Uint16(head[2:4]) {
+		case magicORF, magicORFS:
+			return "orf"
+		case magicPanaRaw:
+			return "rw2"
+		}
+		if string(head[8:10]) == "CR" {
+			return "cr2"
+		}
+		return "tif"
+	}
+	return ""
+}
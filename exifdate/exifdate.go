@@ -1,3 +1,8 @@
+// Package exifdate reads capture dates from photo and video files without external tools.
+//
+// All readers work on an io.ReadSeeker, so files, archive entries and in-memory
+// buffers are handled the same way. Formats it can't handle natively are reported
+// with an error wrapping ErrUnsupported.
 package exifdate
 
 import (
@@ -5,36 +10,66 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 )
 
+// DateTags is the order in which date tags are consulted.
+// Supported names: DateTimeOriginal, CreateDate (DateTimeDigitized), ModifyDate (DateTime), GPSDateTime.
+// Unknown names are ignored.
+var DateTags = []string{"DateTimeOriginal", "CreateDate", "ModifyDate", "GPSDateTime"}
+
+// UseGPSDate enables GPSDateStamp + GPSTimeStamp (GPSDateTime) as a date source.
+var UseGPSDate = true
+
+const (
+	TagExifOffset          = 0x8769
+	TagGPSOffset           = 0x8825
+	TagSubIFDs             = 0x014A
+	TagDateTime            = 0x0132
+	TagDateTimeOriginal    = 0x9003
+	TagDateTimeDigitized   = 0x9004
+	TagSubSecTime          = 0x9290
+	TagSubSecTimeOriginal  = 0x9291
+	TagSubSecTimeDigitized = 0x9292
+	TagOffsetTime          = 0x9010
+	TagOffsetTimeOriginal  = 0x9011
+	TagOffsetTimeDigitized = 0x9012
+
+	// Tags of the GPS IFD
+	TagGPSTimeStamp = 0x0007
+	TagGPSDateStamp = 0x001D
+)
+
+// maxIFDs bounds how many extra directories (SubIFDs and the next-IFD chain) are followed,
+// protecting against corrupted or looping files.
+const maxIFDs = 16
+
+// Some RAW formats are TIFF with a vendor-specific magic number.
 const (
-	TagExifOffset       = 0x8769
-	TagDateTime         = 0x0132
-	TagDateTimeOriginal = 0x9003
+	magicTIFF    = 42
+	magicORF     = 0x4F52 // Olympus "IIRO" / "MMOR"
+	magicORFS    = 0x5352 // Olympus "IIRS"
+	magicPanaRaw = 0x0055 // Panasonic RW2 "IIU\0"
 )
 
 func ParseDate(data []byte) (time.Time, error) {
+	return parseTIFF(data, nil)
+}
+
+// parseTIFF walks IFD0, the Exif IFD and the GPS IFD and returns the date.
+// If md is not nil, the descriptive tags are collected into it along the way.
+func parseTIFF(data []byte, md *Metadata) (time.Time, error) {
 	if len(data) < 8 {
 		// Too short to be any known EXIF/TIFF structure
 		return time.Time{}, fmt.Errorf("%w: data too short", ErrUnsupported)
 	}
 
-	// 1. Determine Endianness (Zero Alloc)
-	// Direct byte comparison is faster than string conversion
-	var order binary.ByteOrder
-	if data[0] == 'I' && data[1] == 'I' {
-		order = binary.LittleEndian
-	} else if data[0] == 'M' && data[1] == 'M' {
-		order = binary.BigEndian
-	} else {
-		return time.Time{}, fmt.Errorf("%w: invalid tiff header", ErrUnsupported)
-	}
-
-	// 2. Check Magic Number
-	if order.Uint16(data[2:4]) != 42 {
-		return time.Time{}, fmt.Errorf("%w: invalid magic number", ErrUnsupported)
+	// 1. Determine Endianness and check Magic Number
+	order, err := tiffByteOrder(data)
+	if err != nil {
+		return time.Time{}, err
 	}
 
 	// 3. Get offset to first IFD
@@ -44,51 +79,199 @@ func ParseDate(data []byte) (time.Time, error) {
 	// We look for:
 	// 1. TagExifOffset (to go deeper)
 	// 2. TagDateTime (as a fallback)
+	// 3. TagGPSOffset (GPS date)
+	// 4. TagSubIFDs (DNG and some RAWs keep more directories there)
+
+	var exifOffset, gpsOffset int
+	var extraIFDs []int
+	var dates dateTags
 
-	var exifOffset int
-	var fallbackDateStr string
-
-	err := iterateTags(data, ifdOffset, order, func(tag uint16, offset int, count uint32) {
-		if tag == TagExifOffset {
-			// Found pointer to Sub-IFD. It's a Long (4 bytes).
-			// It fits inside the value field (bytes 8-12 relative to tag start).
-			// Tag structure: [ID:2][Type:2][Count:4][Value/Offset:4]
-			// The value starts at offset + 8
-			if offset+12 <= len(data) {
-				exifOffset = int(order.Uint32(data[offset+8 : offset+12]))
+	scan := func(dirOffset int) error {
+		return iterateTags(data, dirOffset, order, func(tag uint16, offset int, count uint32) {
+			switch tag {
+			case TagExifOffset:
+				// Found pointer to Sub-IFD. It's a Long (4 bytes).
+				// It fits inside the value field (bytes 8-12 relative to tag start).
+				// Tag structure: [ID:2][Type:2][Count:4][Value/Offset:4]
+				// The value starts at offset + 8
+				if exifOffset == 0 {
+					exifOffset = extractLong(data, offset, order)
+				}
+				return
+			case TagGPSOffset:
+				if gpsOffset == 0 {
+					gpsOffset = extractLong(data, offset, order)
+				}
+				return
+			case TagSubIFDs:
+				extraIFDs = append(extraIFDs, extractLongs(data, offset, count, order)...)
+				return
 			}
-		} else if tag == TagDateTime {
-			// Found Modify Date. Read it just in case we don't find Original.
-			fallbackDateStr = extractString(data, offset, count, order)
-		}
-	})
-	if err != nil {
+			// DateTimeOriginal is not expected in IFD0, but CR3 stores the Exif IFD as a standalone TIFF.
+			dates.collect(data, order, tag, offset, count)
+			if md != nil {
+				md.collect(data, order, tag, offset, count)
+			}
+		})
+	}
+
+	if err := scan(ifdOffset); err != nil {
 		return time.Time{}, fmt.Errorf("%w: tiff structure corruption: %v", ErrUnsupported, err)
 	}
 
 	// --- Pass 2: Scan Exif Sub-IFD (if found) ---
+	exifScanned := exifOffset
 	if exifOffset > 0 {
-		var originalDateStr string
-		_ = iterateTags(data, exifOffset, order, func(tag uint16, offset int, count uint32) {
-			if tag == TagDateTimeOriginal {
-				originalDateStr = extractString(data, offset, count, order)
-			}
-		})
+		_ = scan(exifOffset)
+	}
 
-		// If we found the original date, parse and return immediately
-		if originalDateStr != "" {
-			return parseExifTime(originalDateStr)
+	// --- Pass 3: Scan SubIFDs and IFD1, IFD2... ---
+	// They may point to further directories, so the list grows while we walk it.
+	extraIFDs = append(extraIFDs, nextIFD(data, ifdOffset, order))
+	for i := 0; i < len(extraIFDs) && i < maxIFDs; i++ {
+		off := extraIFDs[i]
+		if off > 0 && off != ifdOffset && !slices.Contains(extraIFDs[:i], off) {
+			_ = scan(off)
+			extraIFDs = append(extraIFDs, nextIFD(data, off, order))
 		}
 	}
+	// The Exif pointer was only found in one of them
+	if exifOffset > 0 && exifOffset != exifScanned {
+		_ = scan(exifOffset)
+	}
 
-	// Fallback
-	if fallbackDateStr != "" {
-		return parseExifTime(fallbackDateStr)
+	if md != nil && gpsOffset > 0 {
+		md.collectGPS(data, gpsOffset, order)
+	}
+
+	// Return the first date found in order of priority
+	for _, name := range DateTags {
+		if name == "GPSDateTime" {
+			if UseGPSDate && gpsOffset > 0 {
+				if t, ok := parseGPSDate(data, gpsOffset, order); ok {
+					if md != nil {
+						md.DateTag, md.DateRaw = name, t.UTC().Format("2006:01:02 15:04:05Z")
+					}
+					return t, nil
+				}
+			}
+			continue
+		}
+		if d := dates.get(name); d.value != "" {
+			if md != nil {
+				md.DateTag, md.DateRaw = name, d.value
+			}
+			return exifDateTime(d.value, d.subsec, d.offset)
+		}
 	}
 
 	return time.Time{}, errors.New("no date tag found")
 }
 
+// parseGPSDate combines GPSDateStamp ("2006:01:02") and GPSTimeStamp (3 rationals: h, m, s).
+// The result is converted to local time, the same zone assumed for other EXIF dates.
+func parseGPSDate(data []byte, gpsOffset int, order binary.ByteOrder) (time.Time, bool) {
+	var dateStr string
+	var hms []float64
+
+	_ = iterateTags(data, gpsOffset, order, func(tag uint16, offset int, count uint32) {
+		switch tag {
+		case TagGPSDateStamp:
+			dateStr = extractString(data, offset, count, order)
+		case TagGPSTimeStamp:
+			hms = extractRationals(data, offset, count, order)
+		}
+	})
+	if dateStr == "" || len(hms) != 3 {
+		return time.Time{}, false
+	}
+
+	d, err := time.Parse("2006:01:02", dateStr)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	secs := hms[0]*3600 + hms[1]*60 + hms[2]
+	t := d.Add(time.Duration(secs * float64(time.Second))).Round(time.Millisecond)
+	return t.In(time.Local), true
+}
+
+// exifDate is a raw date value with its companion SubSecTime and OffsetTime tags.
+type exifDate struct {
+	value, subsec, offset string
+}
+
+// dateTags holds the raw date strings found while walking the IFDs.
+type dateTags struct {
+	original, digitized, modify exifDate
+}
+
+func (d *dateTags) get(name string) exifDate {
+	switch name {
+	case "DateTimeOriginal":
+		return d.original
+	case "CreateDate":
+		return d.digitized
+	case "ModifyDate":
+		return d.modify
+	}
+	return exifDate{}
+}
+
+func (d *dateTags) collect(data []byte, order binary.ByteOrder, tag uint16, offset int, count uint32) {
+	var dst *string
+	switch tag {
+	case TagDateTime:
+		dst = &d.modify.value
+	case TagSubSecTime:
+		dst = &d.modify.subsec
+	case TagOffsetTime:
+		dst = &d.modify.offset
+	case TagDateTimeOriginal:
+		dst = &d.original.value
+	case TagSubSecTimeOriginal:
+		dst = &d.original.subsec
+	case TagOffsetTimeOriginal:
+		dst = &d.original.offset
+	case TagDateTimeDigitized:
+		dst = &d.digitized.value
+	case TagSubSecTimeDigitized:
+		dst = &d.digitized.subsec
+	case TagOffsetTimeDigitized:
+		dst = &d.digitized.offset
+	default:
+		return
+	}
+	// The first directory wins: IFD0 and the Exif IFD are visited before SubIFDs
+	if *dst == "" {
+		*dst = extractString(data, offset, count, order)
+	}
+}
+
+// tiffByteOrder reads the byte order from a TIFF header and validates the magic number.
+func tiffByteOrder(data []byte) (binary.ByteOrder, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("%w: data too short", ErrUnsupported)
+	}
+
+	// Direct byte comparison is faster than string conversion
+	var order binary.ByteOrder
+	if data[0] == 'I' && data[1] == 'I' {
+		order = binary.LittleEndian
+	} else if data[0] == 'M' && data[1] == 'M' {
+		order = binary.BigEndian
+	} else {
+		return nil, fmt.Errorf("%w: invalid tiff header", ErrUnsupported)
+	}
+
+	switch order.Uint16(data[2:4]) {
+	case magicTIFF, magicORF, magicORFS, magicPanaRaw:
+		return order, nil
+	default:
+		return nil, fmt.Errorf("%w: invalid magic number", ErrUnsupported)
+	}
+}
+
 // iterateTags walks a directory and calls 'fn' for every tag.
 // It performs NO allocations.
 // fn arguments: tagID, absoluteOffsetToStartOfTag, valueCount
@@ -116,6 +299,79 @@ func iterateTags(data []byte, dirOffset int, order binary.ByteOrder, fn func(uin
 	return nil
 }
 
+// nextIFD returns the offset of the directory following the one at dirOffset, or 0 at the end of the chain.
+func nextIFD(data []byte, dirOffset int, order binary.ByteOrder) int {
+	if dirOffset+2 > len(data) {
+		return 0
+	}
+	end := dirOffset + 2 + int(order.Uint16(data[dirOffset:dirOffset+2]))*12
+	if end+4 > len(data) {
+		return 0
+	}
+	return int(order.Uint32(data[end : end+4]))
+}
+
+// extractLong reads a single LONG value stored inline in the tag.
+func extractLong(data []byte, tagStartOffset int, order binary.ByteOrder) int {
+	if tagStartOffset+12 > len(data) {
+		return 0
+	}
+	return int(order.Uint32(data[tagStartOffset+8 : tagStartOffset+12]))
+}
+
+// extractLongs reads count LONG values. A single one is stored inline, more are behind an offset.
+func extractLongs(data []byte, tagStartOffset int, count uint32, order binary.ByteOrder) []int {
+	if count == 1 {
+		return []int{extractLong(data, tagStartOffset, order)}
+	}
+	if tagStartOffset+12 > len(data) || count > maxIFDs {
+		return nil
+	}
+
+	start := int(order.Uint32(data[tagStartOffset+8 : tagStartOffset+12]))
+	if start+int(count)*4 > len(data) {
+		return nil
+	}
+
+	vals := make([]int, count)
+	for i := range vals {
+		vals[i] = int(order.Uint32(data[start+i*4:]))
+	}
+	return vals
+}
+
+// extractShort reads a single SHORT value stored inline in the tag.
+func extractShort(data []byte, tagStartOffset int, order binary.ByteOrder) int {
+	if tagStartOffset+10 > len(data) {
+		return 0
+	}
+	return int(order.Uint16(data[tagStartOffset+8 : tagStartOffset+10]))
+}
+
+// extractRationals reads count unsigned RATIONAL values (two LONGs each).
+// They never fit inline, so the value field is always an offset.
+func extractRationals(data []byte, tagStartOffset int, count uint32, order binary.ByteOrder) []float64 {
+	if tagStartOffset+12 > len(data) || count > 16 {
+		return nil
+	}
+	start := int(order.Uint32(data[tagStartOffset+8 : tagStartOffset+12]))
+	if start < 0 || start+int(count)*8 > len(data) {
+		return nil
+	}
+
+	vals := make([]float64, count)
+	for i := range vals {
+		p := start + i*8
+		num := order.Uint32(data[p : p+4])
+		den := order.Uint32(data[p+4 : p+8])
+		if den == 0 {
+			return nil
+		}
+		vals[i] = float64(num) / float64(den)
+	}
+	return vals
+}
+
 // extractString reads the ASCII string from the tag.
 func extractString(data []byte, tagStartOffset int, count uint32, order binary.ByteOrder) string {
 	// Value/Offset field is at tagStartOffset + 8
@@ -148,6 +404,33 @@ func extractString(data []byte, tagStartOffset int, count uint32, order binary.B
 	return string(raw)
 }
 
+// extractComment reads a UserComment: an 8 byte character code, then the text.
+// Only ASCII and undefined codes are read, which is what phones and cameras write.
+func extractComment(data []byte, tagStartOffset int, count uint32, order binary.ByteOrder) string {
+	if count <= 8 {
+		return ""
+	}
+	start := int(order.Uint32(data[tagStartOffset+8 : tagStartOffset+12]))
+	if start < 0 || start+int(count) > len(data) {
+		return ""
+	}
+	raw := data[start : start+int(count)]
+	switch string(raw[:8]) {
+	case "ASCII\x00\x00\x00", "\x00\x00\x00\x00\x00\x00\x00\x00":
+	default:
+		return ""
+	}
+	raw = raw[8:]
+	if idx := bytes.IndexByte(raw, 0); idx != -1 {
+		raw = raw[:idx]
+	}
+	return string(bytes.TrimSpace(raw))
+}
+
+// ExtraLayouts are tried after the built-in layouts, for vendor-specific date strings
+// such as "2006/01/02 15:04:05". They use Go's time layout syntax.
+var ExtraLayouts []string
+
 var nativeLayouts = []string{
 	"2006:01:02 15:04:05",
 	"2006:01:02 15:04:05-07:00",
@@ -157,6 +440,41 @@ var nativeLayouts = []string{
 	"2006-01-02T15:04:05",
 }
 
+// exifDateTime parses the date and applies the companion SubSecTime and OffsetTime tags.
+// SubSec holds the digits after the decimal point: "12" means .12 seconds.
+// Offset ("+02:00", EXIF 2.31) replaces the assumed local zone, keeping the wall time.
+func exifDateTime(s, subsec, offset string) (time.Time, error) {
+	t, err := parseExifTime(s)
+	if err != nil {
+		return t, err
+	}
+
+	if t.Nanosecond() == 0 {
+		ns, digits := 0, 0
+		for _, c := range subsec {
+			if c < '0' || c > '9' || digits == 9 {
+				break
+			}
+			ns = ns*10 + int(c-'0')
+			digits++
+		}
+		for ; digits > 0 && digits < 9; digits++ {
+			ns *= 10
+		}
+		t = t.Add(time.Duration(ns))
+	}
+
+	// Values with an explicit zone already carry it
+	if t.Location() == time.Local {
+		if z, err := time.Parse("-07:00", offset); err == nil {
+			_, off := z.Zone()
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(),
+				time.FixedZone("", off))
+		}
+	}
+	return t, nil
+}
+
 func parseExifTime(s string) (time.Time, error) {
 	if len(s) < 10 || strings.HasPrefix(s, "0000:00:00") || strings.HasPrefix(s, "    :  :  ") {
 		// This is NOT "Unsupported". It is just "No Date".
@@ -164,10 +482,12 @@ func parseExifTime(s string) (time.Time, error) {
 		return time.Time{}, errors.New("date not set")
 	}
 
-	for _, layout := range nativeLayouts {
-		t, err := time.ParseInLocation(layout, s, time.Local)
-		if err == nil {
-			return t, nil
+	for _, layouts := range [][]string{nativeLayouts, ExtraLayouts} {
+		for _, layout := range layouts {
+			t, err := time.ParseInLocation(layout, s, time.Local)
+			if err == nil {
+				return t, nil
+			}
 		}
 	}
 
@@ -5,7 +5,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"time"
 )
@@ -13,62 +15,246 @@ import (
 var (
 	ErrUnsupported = errors.New("unsupported format")
 	exifHeader     = []byte{'E', 'x', 'i', 'f', 0x00, 0x00}
+	xmpHeader      = []byte("http://ns.adobe.com/xap/1.0/\x00")
+	xmpExtHeader   = []byte("http://ns.adobe.com/xmp/extension/\x00")
 )
 
-// Get attempts to find and parse the EXIF date from a file.
-func Get(f *os.File) (time.Time, error) {
-	blob, err := ExtractEXIF(f)
+// maxExtendedXMP bounds the memory allocated for an Extended XMP packet.
+const maxExtendedXMP = 16 << 20
+
+// Aggressive enables a brute-force search for an embedded TIFF header in unrecognized formats.
+// It can rescue obscure camera formats, at the cost of reading AggressiveScanLimit bytes per file.
+var (
+	Aggressive          = false
+	AggressiveScanLimit = 4 << 20
+)
+
+// JPEGScanLimit bounds how many bytes outside of marker segments are read while looking for EXIF and XMP.
+// Segments are skipped by their length and don't count, so large ICC profiles or MPF data don't hide the EXIF.
+var JPEGScanLimit = 1 << 20
+
+// maxTIFFRead bounds how much of a TIFF-based file is loaded into memory.
+// RAW files keep IFD0 and the Exif IFD near the start, the image data comes after.
+const maxTIFFRead = 1 << 20
+
+// Read attempts to find and parse the capture date from r.
+// Images are dated from their EXIF block, movies from their container header.
+func Read(r io.ReadSeeker) (time.Time, error) {
+	md, err := parseFile(r, false)
+	return md.Date, err
+}
+
+// ReadMetadata is like Read, but also returns camera, lens, orientation and GPS data when available.
+func ReadMetadata(r io.ReadSeeker) (Metadata, error) {
+	return parseFile(r, true)
+}
+
+// ReadFS opens name in fsys and reads its capture date.
+// Files that can't seek (e.g. zip entries) are loaded into memory first.
+func ReadFS(fsys fs.FS, name string) (time.Time, error) {
+	f, err := fsys.Open(name)
 	if err != nil {
 		return time.Time{}, err
 	}
-	if blob == nil {
-		return time.Time{}, errors.New("no exif data found")
+	defer f.Close()
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return Read(rs)
 	}
-	return ParseDate(blob)
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return Read(bytes.NewReader(data))
+}
+
+// Get is Read for an open file. Kept for compatibility.
+func Get(f *os.File) (time.Time, error) {
+	return Read(f)
+}
+
+// GetMetadata is ReadMetadata for an open file. Kept for compatibility.
+func GetMetadata(f *os.File) (Metadata, error) {
+	return ReadMetadata(f)
+}
+
+func parseFile(r io.ReadSeeker, full bool) (Metadata, error) {
+	sig, err := sniff(r)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var t time.Time
+	var container string
+	switch {
+	case isQuickTime(sig):
+		t, err = ExtractQuickTimeDate(r)
+		container = "QuickTime"
+	case isAVI(sig):
+		t, err = ExtractAVIDate(r)
+		container = "AVI"
+	case isMatroska(sig):
+		t, err = ExtractMatroskaDate(r)
+		container = "Matroska"
+	case isJPEG(sig):
+		return jpegMetadata(r, full)
+	case isPNG(sig):
+		return pngMetadata(r, full)
+	default:
+		blob, err := extractEXIF(r, sig)
+		if err != nil {
+			return Metadata{}, err
+		}
+		return parseWithFallback(blob, "", "", full)
+	}
+	return Metadata{Date: t, DateTag: container}, err
 }
 
 func ExtractEXIF(r io.ReadSeeker) ([]byte, error) {
-	sniff := make([]byte, 12)
-	if _, err := io.ReadFull(r, sniff); err != nil {
+	sig, err := sniff(r)
+	if err != nil {
+		return nil, err
+	}
+	return extractEXIF(r, sig)
+}
+
+// sniffSize covers the 'ftyp' box with a few compatible brands.
+const sniffSize = 64
+
+// sniff reads the first bytes of the file used for format detection and rewinds the reader.
+// The result is at least 12 bytes long; shorter files are rejected.
+func sniff(r io.ReadSeeker) ([]byte, error) {
+	sig := make([]byte, sniffSize)
+	n, err := io.ReadFull(r, sig)
+	if err != nil && (err != io.ErrUnexpectedEOF || n < 12) {
 		return nil, err
 	}
 
 	if _, err := r.Seek(0, 0); err != nil {
 		return nil, err
 	}
+	return sig[:n], nil
+}
 
+func extractEXIF(r io.ReadSeeker, sig []byte) ([]byte, error) {
 	switch {
-	case bytes.HasPrefix(sniff, []byte{0xFF, 0xD8}):
+	case isJPEG(sig):
 		return extractJPEG(r)
-	case isHEIC(sniff):
+	case isHEIC(sig):
 		return ExtractExifFromHEIC(r)
-	case bytes.HasPrefix(sniff, []byte{0x89, 0x50, 0x4E, 0x47}):
+	case isCR3(sig):
+		return ExtractExifFromCR3(r)
+	case isJXL(sig):
+		return ExtractExifFromJXL(r)
+	case isPNG(sig):
 		return extractPNG(r)
+	case isTIFFBased(sig):
+		return extractTIFF(r)
+	case Aggressive:
+		return scanForTIFF(r)
 	default:
 		return nil, ErrUnsupported
 	}
 }
 
+// heifBrands covers HEIC stills and sequences (.heics), AVC-coded HEIF and AVIF.
+// AVIF uses the same HEIF container (meta/iinf/iloc), only the codec differs.
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "hevm": true, "hevs": true,
+	"mif1": true, "mif2": true, "msf1": true,
+	"avci": true, "avcs": true,
+	"avif": true, "avis": true,
+}
+
+// isHEIC checks the major brand and, if that doesn't match, the compatible brands of the 'ftyp' box.
 func isHEIC(sig []byte) bool {
 	if !bytes.Equal(sig[4:8], []byte("ftyp")) {
 		return false
 	}
-	brand := string(sig[8:12])
-	return brand == "heic" || brand == "heix" || brand == "mif1" || brand == "msf1"
+	if heifBrands[string(sig[8:12])] {
+		return true
+	}
+
+	// [size:4]['ftyp':4][major:4][minor_version:4][compatible:4]...
+	// Only the structural still-image brands count here: some video files list codec brands too.
+	end := min(int(binary.BigEndian.Uint32(sig[0:4])), len(sig))
+	for i := 16; i+4 <= end; i += 4 {
+		switch string(sig[i : i+4]) {
+		case "mif1", "mif2", "heic", "heix", "avif":
+			return true
+		}
+	}
+	return false
+}
+
+func isJPEG(sig []byte) bool {
+	return bytes.HasPrefix(sig, []byte{0xFF, 0xD8})
 }
 
 func extractJPEG(r io.Reader) ([]byte, error) {
+	blob, _, err := scanJPEG(r, false)
+	return blob, err
+}
+
+// jpegMetadata prefers EXIF and falls back to the XMP packet.
+// Edited JPEGs (e.g. Lightroom exports) sometimes keep only xmp:CreateDate.
+func jpegMetadata(r io.Reader, full bool) (Metadata, error) {
+	blob, xmp, err := scanJPEG(r, true)
+	if err != nil && blob == nil && xmp == nil {
+		return Metadata{}, err
+	}
+	return parseWithFallback(blob, "XMP", xmpDate(xmp), full)
+}
+
+// parseWithFallback parses the EXIF blob and uses the textual date if EXIF has none.
+// textTag names the source of textDate for Metadata.DateTag.
+func parseWithFallback(blob []byte, textTag, textDate string, full bool) (Metadata, error) {
+	var md Metadata
+	if blob != nil {
+		var err error
+		if full {
+			md, err = ParseMetadata(blob)
+		} else {
+			md.Date, err = ParseDate(blob)
+		}
+		if err == nil || textDate == "" {
+			return md, err
+		}
+	}
+
+	if textDate == "" {
+		return md, errors.New("no exif data found")
+	}
+
+	var err error
+	md.Date, err = parseTextDate(textDate)
+	md.DateTag, md.DateRaw = textTag, textDate
+	return md, err
+}
+
+// scanJPEG walks the JPEG markers up to the image data and returns the EXIF and XMP APP1 payloads.
+// EXIF split over consecutive APP1 segments is joined, and Extended XMP is appended to the main packet.
+// Without wantXMP it stops as soon as EXIF is complete.
+func scanJPEG(r io.Reader, wantXMP bool) (exif, xmp []byte, err error) {
 	br := bufio.NewReader(r)
 	var sizeBuf [2]byte
 
-	maxScan := 1 << 20 // 1MB scan limit for performance
+	var exifOpen, needExt bool
+	var extXMP []byte
+	defer func() {
+		if extXMP != nil {
+			xmp = append(xmp, extXMP...)
+		}
+	}()
+
 	scanned := 0
 
-	for scanned < maxScan {
+	for scanned < JPEGScanLimit {
 		// 1. Find Start of Marker (0xFF)
 		b, err := br.ReadByte()
 		if err != nil {
-			return nil, err
+			return exif, xmp, err
 		}
 		scanned++
 
@@ -81,7 +267,7 @@ func extractJPEG(r io.Reader) ([]byte, error) {
 		for {
 			marker, err = br.ReadByte()
 			if err != nil {
-				return nil, err
+				return exif, xmp, err
 			}
 			scanned++
 			if marker != 0xFF {
@@ -94,7 +280,7 @@ func extractJPEG(r io.Reader) ([]byte, error) {
 			continue
 		}
 		if marker == 0xD9 || marker == 0xDA { // EOI or SOS (Scan data starts, stop looking)
-			return nil, nil
+			return exif, xmp, nil
 		}
 		// Skip standalone markers
 		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
@@ -103,85 +289,126 @@ func extractJPEG(r io.Reader) ([]byte, error) {
 
 		// 4. Read Length
 		if _, err := io.ReadFull(br, sizeBuf[:]); err != nil {
-			return nil, err
+			return exif, xmp, err
 		}
 		length := int(binary.BigEndian.Uint16(sizeBuf[:])) - 2
 		scanned += 2
 
-		// 5. Check for APP1 Exif
+		// 5. Check for APP1 Exif / XMP
+		var sig []byte
 		if marker == 0xE1 && length >= 6 {
-			sig, err := br.Peek(6)
-			if err == nil && bytes.Equal(sig, exifHeader) {
-				data := make([]byte, length)
-				if _, err := io.ReadFull(br, data); err != nil {
-					return nil, err
-				}
-				return data[6:], nil
+			sig, _ = br.Peek(min(length, len(xmpExtHeader)))
+		}
+		isExif := bytes.HasPrefix(sig, exifHeader) && (exif == nil || exifOpen)
+		isXMP := wantXMP && xmp == nil && bytes.HasPrefix(sig, xmpHeader)
+		isExtXMP := wantXMP && bytes.HasPrefix(sig, xmpExtHeader)
+
+		// EXIF is complete with the first segment that doesn't continue it
+		if exifOpen && !isExif {
+			exifOpen = false
+			if !wantXMP {
+				return exif, nil, nil
+			}
+		}
+
+		if isExif || isXMP || isExtXMP {
+			data := make([]byte, length)
+			if _, err := io.ReadFull(br, data); err != nil {
+				return exif, xmp, err
+			}
+
+			switch {
+			case isExif:
+				exif = append(exif, data[len(exifHeader):]...)
+				exifOpen = true
+			case isXMP:
+				xmp = data[len(xmpHeader):]
+				needExt = bytes.Contains(xmp, []byte("HasExtendedXMP"))
+			default:
+				extXMP = placeExtendedXMP(extXMP, data[len(xmpExtHeader):])
 			}
+			continue
 		}
 
-		// 6. Enforce Limit
-		if length > (maxScan - scanned) {
-			return nil, nil
+		if exif != nil && xmp != nil && !needExt {
+			return exif, xmp, nil
 		}
 
-		// 7. Skip Payload
+		// 6. Skip Payload
 		if length > 0 {
-			skipped, err := br.Discard(length)
-			if err != nil {
-				return nil, err
+			if _, err := br.Discard(length); err != nil {
+				return exif, xmp, err
 			}
-			scanned += skipped
 		}
 	}
 
-	return nil, nil
+	return exif, xmp, nil
 }
 
-// extractPNG walks through PNG chunks looking for the "eXIf" chunk.
-func extractPNG(r io.Reader) ([]byte, error) {
-	if _, err := io.CopyN(io.Discard, r, 8); err != nil {
-		return nil, err
+// placeExtendedXMP copies one Extended XMP chunk into the full packet, allocating it on the first chunk.
+// Chunk layout: [GUID:32][full length:4][offset:4][data...]
+func placeExtendedXMP(ext, chunk []byte) []byte {
+	if len(chunk) < 40 {
+		return ext
 	}
+	total := int(binary.BigEndian.Uint32(chunk[32:36]))
+	offset := int(binary.BigEndian.Uint32(chunk[36:40]))
+	data := chunk[40:]
 
-	// Buffer for Length (4 bytes) and Type (4 bytes)
-	header := make([]byte, 8)
-
-	for {
-		if _, err := io.ReadFull(r, header); err != nil {
-			if err == io.EOF {
-				return nil, nil // End of file, no EXIF found
-			}
-			return nil, err
+	if ext == nil {
+		if total > maxExtendedXMP {
+			return nil
 		}
+		ext = make([]byte, total)
+	}
+	if len(ext) != total || offset+len(data) > total {
+		return ext
+	}
+	copy(ext[offset:], data)
+	return ext
+}
 
-		length := binary.BigEndian.Uint32(header[0:4])
-		chunkType := string(header[4:8])
+// isTIFFBased accepts both standard TIFF headers and the RAW variants (ORF, RW2).
+func isTIFFBased(sig []byte) bool {
+	_, err := tiffByteOrder(sig)
+	return err == nil
+}
 
-		if chunkType == "eXIf" {
-			// Sanity check: EXIF shouldn't be massive (usually < 64KB)
-			// Limit to 10MB to prevent OOM attacks
-			if length > 10*1024*1024 {
-				return nil, errors.New("exif data too large")
-			}
+// scanForTIFF searches the start of the file for TIFF headers and returns
+// the first candidate that yields a date. Misses stay ErrUnsupported so ExifTool still gets a chance.
+func scanForTIFF(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(AggressiveScanLimit)))
+	if err != nil {
+		return nil, err
+	}
 
-			data := make([]byte, length)
-			if _, err := io.ReadFull(r, data); err != nil {
-				return nil, err
-			}
-			// Note: PNG eXIf chunks contain the raw TIFF structure (II/MM...)
-			// They usually do NOT have the "Exif\0\0" header that JPEG has,
-			// so we return the data as-is.
-			return data, nil
+	for pos := 0; pos < len(data); {
+		idx := indexTIFF(data[pos:])
+		if idx < 0 {
+			break
 		}
-
-		if chunkType == "IEND" {
-			return nil, nil
+		candidate := data[pos+idx:]
+		if _, err := ParseDate(candidate); err == nil {
+			return candidate, nil
 		}
+		pos += idx + 1
+	}
+	return nil, fmt.Errorf("%w: no embedded tiff found", ErrUnsupported)
+}
 
-		skipAmount := int64(length) + 4 // Skip Payload + CRC
-		if _, err := io.CopyN(io.Discard, r, skipAmount); err != nil {
-			return nil, err
-		}
+// indexTIFF returns the position of the first standard TIFF header ("II*\0" or "MM\0*"), or -1.
+func indexTIFF(data []byte) int {
+	ii := bytes.Index(data, []byte{'I', 'I', 0x2A, 0x00})
+	mm := bytes.Index(data, []byte{'M', 'M', 0x00, 0x2A})
+	if ii < 0 || (mm >= 0 && mm < ii) {
+		return mm
 	}
+	return ii
+}
+
+// extractTIFF handles plain TIFF files and TIFF-based RAW formats (CR2, NEF, ARW, DNG, PEF, ORF, RW2).
+// The file itself is the TIFF structure ParseDate expects, so no unwrapping is needed.
+// If the date lives beyond the read limit, ParseDate reports ErrUnsupported and ExifTool takes over.
+func extractTIFF(r io.Reader) ([]byte, error) {
+	return io.ReadAll(io.LimitReader(r, maxTIFFRead))
 }
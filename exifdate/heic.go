@@ -42,50 +42,193 @@ func ExtractExifFromHEIC(r io.ReadSeeker) ([]byte, error) {
 		return nil, fmt.Errorf("iloc box not found: %w", err)
 	}
 
-	// We only need the location for the first Exif ID found
-	targetID := exifItemIDs[0]
-	locs, err := parseIloc(r, iloc.dataOffset, iloc.dataSize, targetID)
+	// 'idat' (data stored in 'meta') and 'iref' (item references) are optional
+	items := &heifItems{r: r, iloc: iloc}
+	if idat, err := findBox(r, metaChildrenOffset, metaChildrenEnd, "idat"); err == nil {
+		items.idat = &idat
+	}
+	if iref, err := findBox(r, metaChildrenOffset, metaChildrenEnd, "iref"); err == nil {
+		items.iref = &iref
+	}
+
+	// Some encoders produce several Exif items (e.g. for gain maps) and the first may be empty.
+	// Return the first one with a usable date, or at least the first valid TIFF blob.
+	var firstBlob []byte
+	var lastErr error
+	for _, id := range exifItemIDs {
+		blob, err := items.readExif(id)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if blob == nil {
+			continue
+		}
+		if _, err := ParseDate(blob); err == nil {
+			return blob, nil
+		}
+		if firstBlob == nil {
+			firstBlob = blob
+		}
+	}
+
+	if firstBlob != nil {
+		return firstBlob, nil
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, nil
+}
+
+// maxItemDepth bounds how deep construction_method 2 references are followed.
+const maxItemDepth = 4
+
+// heifItems reads item data described by the boxes of 'meta'.
+type heifItems struct {
+	r    io.ReadSeeker
+	iloc boxHeader
+	idat *boxHeader // nil if the file has no 'idat'
+	iref *boxHeader // nil if the file has no 'iref'
+}
+
+// readExif reads one Exif item and returns the raw TIFF data.
+func (h *heifItems) readExif(itemID uint32) ([]byte, error) {
+	itemData, err := h.readItem(itemID, 0)
 	if err != nil {
-		return nil, fmt.Errorf("%w: failed to parse iloc: %v", ErrUnsupported, err)
+		return nil, err
 	}
 
+	// Clean up the Exif wrapper (4 byte offset + "Exif\0\0") to get raw TIFF
+	return stripExifWrapper(itemData), nil
+}
+
+// readItem concatenates the extents of an item. Each extent is located by the construction method:
+// 0 - absolute file offset, 1 - offset into 'idat', 2 - offset into the data of another item,
+// referenced from this one with an 'iloc' reference in 'iref'.
+func (h *heifItems) readItem(itemID uint32, depth int) ([]byte, error) {
+	locs, err := parseIloc(h.r, h.iloc.dataOffset, h.iloc.dataSize, itemID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse iloc: %v", ErrUnsupported, err)
+	}
 	if len(locs) == 0 {
-		return nil, fmt.Errorf("%w: exif item location definition not found", ErrUnsupported)
+		return nil, fmt.Errorf("%w: location of item %d not found", ErrUnsupported, itemID)
 	}
 
-	// 5. Determine if 'idat' is required and scan for it only if necessary.
-	var idatOffset uint64
-	needsIdat := false
-
-	// Check if any extent uses construction_method 1 (idat-relative)
+	var out bytes.Buffer
 	for _, loc := range locs {
-		if loc.constructionMethod == 1 {
-			needsIdat = true
-			break
-		}
-	}
+		for _, ext := range loc.extents {
+			switch loc.constructionMethod {
+			case 0, 1:
+				if ext.length == 0 {
+					continue
+				}
+				offset := loc.baseOffset + ext.offset
+				if loc.constructionMethod == 1 {
+					if h.idat == nil {
+						return nil, fmt.Errorf("%w: item uses idat-relative offset but idat box not found", ErrUnsupported)
+					}
+					offset += h.idat.dataOffset
+				}
+				if _, err := h.r.Seek(int64(offset), io.SeekStart); err != nil {
+					return nil, err
+				}
+				if _, err := io.CopyN(&out, h.r, int64(ext.length)); err != nil {
+					return nil, err
+				}
+
+			case 2:
+				data, err := h.readReferenced(itemID, ext.index, depth)
+				if err != nil {
+					return nil, err
+				}
+				start := loc.baseOffset + ext.offset
+				end := uint64(len(data))
+				if ext.length > 0 {
+					end = start + ext.length
+				}
+				if start > end || end > uint64(len(data)) {
+					return nil, fmt.Errorf("%w: extent of item %d out of bounds", ErrUnsupported, itemID)
+				}
+				out.Write(data[start:end])
 
-	if needsIdat {
-		_ = scanBoxes(r, 0, ^uint64(0), func(b boxHeader) (bool, error) {
-			if b.typ == "idat" {
-				idatOffset = b.dataOffset
-				return true, nil
+			default:
+				return nil, fmt.Errorf("%w: unknown construction method %d", ErrUnsupported, loc.constructionMethod)
 			}
-			return false, nil
-		})
+		}
+	}
+	return out.Bytes(), nil
+}
 
-		// Note: If idat is needed but not found, idatOffset remains 0.
-		// readItemData handles the error if it sees constructionMethod 1 and idatOffset 0.
+// readReferenced reads the item an extent with construction_method 2 points to.
+// extent_index is 1-based; 0 (no index field) means the first reference.
+func (h *heifItems) readReferenced(itemID uint32, index uint64, depth int) ([]byte, error) {
+	if depth >= maxItemDepth {
+		return nil, fmt.Errorf("%w: item references nested too deep", ErrUnsupported)
+	}
+	if h.iref == nil {
+		return nil, fmt.Errorf("%w: item %d references other items but iref box not found", ErrUnsupported, itemID)
 	}
 
-	// 6. Read the data
-	itemData, err := readItemData(r, locs, idatOffset)
+	refs, err := parseIref(h.r, *h.iref, itemID, "iloc")
 	if err != nil {
+		return nil, fmt.Errorf("%w: failed to parse iref: %v", ErrUnsupported, err)
+	}
+	index = max(index, 1)
+	if index > uint64(len(refs)) {
+		return nil, fmt.Errorf("%w: reference %d of item %d not found", ErrUnsupported, index, itemID)
+	}
+	return h.readItem(refs[index-1], depth+1)
+}
+
+// parseIref returns the items referenced by fromID with a reference of type refType.
+// iref is a FullBox; version 0 uses 16-bit item IDs, version 1 32-bit.
+// Each child box is named after the reference type: [from_item_ID][reference_count:2][to_item_ID...]
+func parseIref(r io.ReadSeeker, iref boxHeader, fromID uint32, refType string) ([]uint32, error) {
+	if iref.dataSize < 4 {
+		return nil, errors.New("iref too small")
+	}
+	if _, err := r.Seek(int64(iref.dataOffset), io.SeekStart); err != nil {
+		return nil, err
+	}
+	var vf [4]byte
+	if _, err := io.ReadFull(r, vf[:]); err != nil {
 		return nil, err
 	}
+	idSize := 2
+	if vf[0] != 0 {
+		idSize = 4
+	}
+	readID := func(b []byte) uint32 {
+		if idSize == 2 {
+			return uint32(binary.BigEndian.Uint16(b))
+		}
+		return binary.BigEndian.Uint32(b)
+	}
 
-	// 7. Clean up the Exif wrapper (4 byte offset + "Exif\0\0") to get raw TIFF
-	return stripExifWrapper(itemData), nil
+	var refs []uint32
+	err := scanBoxes(r, iref.dataOffset+4, iref.dataOffset+iref.dataSize, func(b boxHeader) (bool, error) {
+		if b.typ != refType || b.dataSize < uint64(idSize+2) || b.dataSize > 64*1024 {
+			return false, nil
+		}
+		buf := make([]byte, b.dataSize)
+		if _, err := r.Seek(int64(b.dataOffset), io.SeekStart); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return false, err
+		}
+		if readID(buf) != fromID {
+			return false, nil
+		}
+
+		count := int(binary.BigEndian.Uint16(buf[idSize:]))
+		for i, pos := 0, idSize+2; i < count && pos+idSize <= len(buf); i, pos = i+1, pos+idSize {
+			refs = append(refs, readID(buf[pos:]))
+		}
+		return true, nil
+	})
+	return refs, err
 }
 
 // -------------------------------------------------------------------------
@@ -278,6 +421,7 @@ type itemLocation struct {
 	extents            []extent
 }
 type extent struct {
+	index  uint64 // Only used by construction_method 2
 	offset uint64
 	length uint64
 }
@@ -415,9 +559,10 @@ func parseIloc(r io.ReadSeeker, offset, size uint64, targetID uint32) ([]itemLoc
 
 		// 5. Iterate over extents
 		for e := 0; e < int(extentCount); e++ {
-			// Read (and ignore) Index if present
+			// Read Index if present
+			var index uint64
 			if version >= 1 && indexSize > 0 {
-				if _, err := readUint(indexSize); err != nil {
+				if index, err = readUint(indexSize); err != nil {
 					return nil, err
 				}
 			}
@@ -434,7 +579,7 @@ func parseIloc(r io.ReadSeeker, offset, size uint64, targetID uint32) ([]itemLoc
 
 			// Only store the data if this is the item we are looking for
 			if isTarget {
-				currentExtents = append(currentExtents, extent{offset: off, length: lenVal})
+				currentExtents = append(currentExtents, extent{index: index, offset: off, length: lenVal})
 			}
 		}
 
@@ -450,42 +595,6 @@ func parseIloc(r io.ReadSeeker, offset, size uint64, targetID uint32) ([]itemLoc
 	return locs, nil
 }
 
-func readItemData(r io.ReadSeeker, locs []itemLocation, idatOffset uint64) ([]byte, error) {
-	var out bytes.Buffer
-
-	for _, loc := range locs {
-		for _, ext := range loc.extents {
-			var finalOffset int64
-
-			// 0: Absolute, 1: Relative to idat
-			switch loc.constructionMethod {
-			case 0:
-				finalOffset = int64(loc.baseOffset + ext.offset)
-			case 1:
-				if idatOffset == 0 {
-					return nil, fmt.Errorf("%w: item uses idat-relative offset but idat box not found", ErrUnsupported)
-				}
-				finalOffset = int64(idatOffset + loc.baseOffset + ext.offset)
-			default:
-				finalOffset = int64(loc.baseOffset + ext.offset)
-			}
-
-			if ext.length == 0 {
-				continue
-			}
-
-			_, err := r.Seek(finalOffset, io.SeekStart)
-			if err != nil {
-				return nil, err
-			}
-			if _, err := io.CopyN(&out, r, int64(ext.length)); err != nil {
-				return nil, err
-			}
-		}
-	}
-	return out.Bytes(), nil
-}
-
 func stripExifWrapper(data []byte) []byte {
 	// The standard HEIC Exif wrapper is: [4-byte offset] + [padding] + "Exif\0\0" + [TIFF Header]
 	if len(data) >= 4 {
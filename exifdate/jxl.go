@@ -0,0 +1,73 @@
+package exifdate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// jxlSignature is the 'JXL ' signature box that starts the ISO-BMFF flavour of JPEG XL.
+var jxlSignature = []byte{0x00, 0x00, 0x00, 0x0C, 'J', 'X', 'L', ' ', 0x0D, 0x0A, 0x87, 0x0A}
+
+// isJXL matches both the container and the bare codestream (FF 0A).
+func isJXL(sig []byte) bool {
+	return bytes.Equal(sig[:12], jxlSignature) || bytes.HasPrefix(sig, []byte{0xFF, 0x0A})
+}
+
+// ExtractExifFromJXL returns the TIFF payload of the 'Exif' box of a JPEG XL container.
+func ExtractExifFromJXL(r io.ReadSeeker) ([]byte, error) {
+	var sig [2]byte
+	if _, err := io.ReadFull(r, sig[:]); err != nil {
+		return nil, err
+	}
+	if sig[0] == 0xFF && sig[1] == 0x0A {
+		// A bare codestream has no room for metadata.
+		return nil, nil
+	}
+
+	var exifBox boxHeader
+	found := false
+	err := scanBoxes(r, 0, ^uint64(0), func(b boxHeader) (bool, error) {
+		switch b.typ {
+		case "Exif":
+			exifBox = b
+			found = true
+			return true, nil
+		case "brob":
+			// Brotli-compressed box. The first 4 bytes hold the original type.
+			var typ [4]byte
+			if _, err := io.ReadFull(r, typ[:]); err != nil {
+				return false, err
+			}
+			if string(typ[:]) == "Exif" {
+				return true, fmt.Errorf("%w: compressed exif box", ErrUnsupported)
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+
+	// Payload: [4 bytes offset to TIFF header] + [Data]
+	if exifBox.dataSize < 4 || exifBox.dataSize > maxTIFFRead {
+		return nil, fmt.Errorf("%w: invalid exif box size", ErrUnsupported)
+	}
+	if _, err := r.Seek(int64(exifBox.dataOffset), io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, exifBox.dataSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	offset := uint64(binary.BigEndian.Uint32(data[0:4]))
+	if 4+offset >= uint64(len(data)) {
+		return nil, fmt.Errorf("%w: invalid tiff header offset", ErrUnsupported)
+	}
+	return data[4+offset:], nil
+}
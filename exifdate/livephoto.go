@@ -0,0 +1,105 @@
+package exifdate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// keyContentID is the 'moov/meta' key holding the Live Photo identifier of an Apple movie.
+const keyContentID = "com.apple.quicktime.content.identifier"
+
+const (
+	TagMakerNote = 0x927C
+
+	// Tags of the Apple MakerNote
+	TagAppleContentID = 0x0011
+)
+
+// appleMakerNote starts the Apple MakerNote: the header, a version and "MM".
+// The IFD follows it, with offsets counted from the start of the MakerNote.
+var appleMakerNote = []byte("Apple iOS\x00")
+
+const appleMakerNoteIFD = 14
+
+// ContentIdentifier returns the Apple ContentIdentifier shared by the photo and the video of a Live Photo.
+// Photos keep it in the Apple MakerNote, movies in the QuickTime metadata.
+// It returns "" if r has none.
+func ContentIdentifier(r io.ReadSeeker) (string, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	sig, err := sniff(r)
+	if err != nil {
+		return "", err
+	}
+
+	if isQuickTime(sig) {
+		moov, err := findBox(r, 0, ^uint64(0), "moov")
+		if err != nil {
+			return "", fmt.Errorf("%w: moov box not found: %v", ErrUnsupported, err)
+		}
+		meta, err := findBox(r, moov.dataOffset, moov.dataOffset+moov.dataSize, "meta")
+		if err != nil {
+			return "", nil
+		}
+		id, _ := readQuickTimeKey(r, meta, keyContentID)
+		return id, nil
+	}
+
+	blob, err := extractEXIF(r, sig)
+	if err != nil {
+		return "", err
+	}
+	return parseContentID(blob)
+}
+
+// parseContentID finds the MakerNote in the Exif IFD of a TIFF blob and reads the identifier from it.
+func parseContentID(data []byte) (string, error) {
+	if len(data) < 8 {
+		return "", fmt.Errorf("%w: data too short", ErrUnsupported)
+	}
+	order, err := tiffByteOrder(data)
+	if err != nil {
+		return "", err
+	}
+
+	var exifOffset int
+	err = iterateTags(data, int(order.Uint32(data[4:8])), order, func(tag uint16, offset int, count uint32) {
+		if tag == TagExifOffset && exifOffset == 0 {
+			exifOffset = extractLong(data, offset, order)
+		}
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: tiff structure corruption: %v", ErrUnsupported, err)
+	}
+	if exifOffset == 0 {
+		return "", nil
+	}
+
+	var note []byte
+	_ = iterateTags(data, exifOffset, order, func(tag uint16, offset int, count uint32) {
+		if tag != TagMakerNote || count <= 4 {
+			return
+		}
+		start := extractLong(data, offset, order)
+		if start > 0 && start+int(count) <= len(data) {
+			note = data[start : start+int(count)]
+		}
+	})
+	if !bytes.HasPrefix(note, appleMakerNote) || len(note) < appleMakerNoteIFD {
+		return "", nil
+	}
+
+	var id string
+	if err := iterateTags(note, appleMakerNoteIFD, binary.BigEndian, func(tag uint16, offset int, count uint32) {
+		if tag == TagAppleContentID {
+			id = extractString(note, offset, count, binary.BigEndian)
+		}
+	}); err != nil {
+		return "", errors.New("corrupted Apple MakerNote")
+	}
+	return id, nil
+}
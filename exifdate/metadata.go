@@ -0,0 +1,123 @@
+package exifdate
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+const (
+	TagMake        = 0x010F
+	TagModel       = 0x0110
+	TagOrientation = 0x0112
+	TagSoftware    = 0x0131
+	TagArtist      = 0x013B
+	TagOwnerName   = 0xA430 // CameraOwnerName
+	TagSerial      = 0xA431 // BodySerialNumber
+	TagUserComment = 0x9286
+	TagLensModel   = 0xA434
+	TagFNumber     = 0x829D
+	TagISO         = 0x8827 // ISOSpeedRatings, PhotographicSensitivity since Exif 2.3
+
+	// Tags of the GPS IFD
+	TagGPSLatitudeRef  = 0x0001
+	TagGPSLatitude     = 0x0002
+	TagGPSLongitudeRef = 0x0003
+	TagGPSLongitude    = 0x0004
+)
+
+// Metadata is the descriptive subset of EXIF collected alongside the date.
+// Fields are left empty when the file doesn't have them (e.g. videos only provide Date).
+type Metadata struct {
+	Date time.Time
+	// DateTag names where Date came from (e.g. "DateTimeOriginal", "XMP", "QuickTime")
+	// and DateRaw holds the value as stored in the file, if it was text.
+	DateTag string
+	DateRaw string
+
+	Make        string
+	Model       string
+	LensModel   string
+	Orientation int
+	ISO         int
+	FNumber     float64
+	Software    string
+	Artist      string
+	OwnerName   string
+	Serial      string // Serial number of the camera body
+	UserComment string // e.g. "Screenshot" on Apple devices
+
+	HasGPS    bool
+	Latitude  float64
+	Longitude float64
+}
+
+// ParseMetadata parses a TIFF blob like ParseDate, but also collects the descriptive tags.
+// The error is the one ParseDate would return; the other fields are filled even if the date is missing.
+func ParseMetadata(data []byte) (Metadata, error) {
+	var md Metadata
+	t, err := parseTIFF(data, &md)
+	md.Date = t
+	return md, err
+}
+
+func (md *Metadata) collect(data []byte, order binary.ByteOrder, tag uint16, offset int, count uint32) {
+	switch tag {
+	case TagMake:
+		md.Make = extractString(data, offset, count, order)
+	case TagModel:
+		md.Model = extractString(data, offset, count, order)
+	case TagLensModel:
+		md.LensModel = extractString(data, offset, count, order)
+	case TagSoftware:
+		md.Software = extractString(data, offset, count, order)
+	case TagArtist:
+		md.Artist = extractString(data, offset, count, order)
+	case TagOwnerName:
+		md.OwnerName = extractString(data, offset, count, order)
+	case TagSerial:
+		md.Serial = extractString(data, offset, count, order)
+	case TagUserComment:
+		md.UserComment = extractComment(data, offset, count, order)
+	case TagOrientation:
+		md.Orientation = extractShort(data, offset, order)
+	case TagISO:
+		md.ISO = extractShort(data, offset, order)
+	case TagFNumber:
+		if v := extractRationals(data, offset, 1, order); v != nil {
+			md.FNumber = v[0]
+		}
+	}
+}
+
+// collectGPS reads the position from the GPS IFD.
+// Latitude and Longitude are degrees, minutes, seconds; the Ref tags give the hemisphere.
+func (md *Metadata) collectGPS(data []byte, gpsOffset int, order binary.ByteOrder) {
+	var latRef, lonRef string
+	var lat, lon []float64
+
+	_ = iterateTags(data, gpsOffset, order, func(tag uint16, offset int, count uint32) {
+		switch tag {
+		case TagGPSLatitudeRef:
+			latRef = extractString(data, offset, count, order)
+		case TagGPSLatitude:
+			lat = extractRationals(data, offset, count, order)
+		case TagGPSLongitudeRef:
+			lonRef = extractString(data, offset, count, order)
+		case TagGPSLongitude:
+			lon = extractRationals(data, offset, count, order)
+		}
+	})
+	if len(lat) != 3 || len(lon) != 3 {
+		return
+	}
+
+	md.Latitude = lat[0] + lat[1]/60 + lat[2]/3600
+	md.Longitude = lon[0] + lon[1]/60 + lon[2]/3600
+	if latRef == "S" {
+		md.Latitude = -md.Latitude
+	}
+	if lonRef == "W" {
+		md.Longitude = -md.Longitude
+	}
+	md.HasGPS = true
+}
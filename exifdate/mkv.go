@@ -0,0 +1,155 @@
+package exifdate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// EBML element IDs (with their length marker bits, as they appear in the file).
+const (
+	ebmlHeader  = 0x1A45DFA3
+	mkvSegment  = 0x18538067
+	mkvInfo     = 0x1549A966
+	mkvCluster  = 0x1F43B675
+	mkvDateUTC  = 0x4461
+	mkvEpochSec = 978307200 // 2001-01-01T00:00:00Z, the Matroska epoch
+)
+
+// Enough to get past SeekHead, Void, Tracks etc. without walking the whole file.
+const maxEBMLElements = 64
+
+func isMatroska(sig []byte) bool {
+	return bytes.HasPrefix(sig, []byte{0x1A, 0x45, 0xDF, 0xA3})
+}
+
+// ExtractMatroskaDate reads Segment/Info/DateUTC from an MKV or WebM file.
+// DateUTC is nanoseconds since 2001-01-01 UTC, so the result is in UTC.
+func ExtractMatroskaDate(r io.ReadSeeker) (time.Time, error) {
+	pos := int64(0)
+	end := int64(-1) // Unknown until we are inside the Segment
+
+	for range maxEBMLElements {
+		id, size, hdrLen, err := readEBMLHeader(r, pos)
+		if err != nil {
+			break
+		}
+		dataPos := pos + hdrLen
+
+		switch id {
+		case mkvSegment:
+			// Descend: children follow immediately
+			pos = dataPos
+			if size >= 0 {
+				end = dataPos + size
+			}
+			continue
+		case mkvInfo:
+			return readDateUTC(r, dataPos, size)
+		case mkvCluster:
+			// Media data begins, Info would have come before it
+			return time.Time{}, fmt.Errorf("%w: no info element before clusters", ErrUnsupported)
+		}
+
+		if size < 0 {
+			break // Unknown-size element we can't skip
+		}
+		pos = dataPos + size
+		if end >= 0 && pos >= end {
+			break
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("%w: matroska info not found", ErrUnsupported)
+}
+
+func readDateUTC(r io.ReadSeeker, start, size int64) (time.Time, error) {
+	if size < 0 {
+		return time.Time{}, fmt.Errorf("%w: unknown info size", ErrUnsupported)
+	}
+
+	pos := start
+	for pos < start+size {
+		id, elSize, hdrLen, err := readEBMLHeader(r, pos)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if elSize < 0 {
+			break
+		}
+
+		if id == mkvDateUTC {
+			if elSize != 8 {
+				return time.Time{}, fmt.Errorf("%w: invalid DateUTC size", ErrUnsupported)
+			}
+			var buf [8]byte
+			if _, err := io.ReadFull(r, buf[:]); err != nil {
+				return time.Time{}, err
+			}
+			ns := int64(binary.BigEndian.Uint64(buf[:]))
+			return time.Unix(mkvEpochSec, 0).Add(time.Duration(ns)).UTC(), nil
+		}
+
+		pos += hdrLen + elSize
+	}
+
+	// Screen recorders often don't write it; ExifTool won't find anything else either.
+	return time.Time{}, errors.New("date not set")
+}
+
+// readEBMLHeader reads an element ID and data size at pos.
+// A size of -1 means "unknown" (all data bits set), used by live streams.
+func readEBMLHeader(r io.ReadSeeker, pos int64) (id uint32, size int64, hdrLen int64, err error) {
+	if _, err := r.Seek(pos, io.SeekStart); err != nil {
+		return 0, 0, 0, err
+	}
+
+	idVal, idLen, err := readVint(r, 4)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	// IDs keep their marker bits
+	id = uint32(idVal | 1<<(7*idLen))
+
+	sizeVal, sizeLen, err := readVint(r, 8)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	size = int64(sizeVal)
+	if sizeVal == 1<<(7*sizeLen)-1 {
+		size = -1
+	}
+
+	return id, size, int64(idLen + sizeLen), nil
+}
+
+// readVint reads a variable-length integer and returns its value without the marker bit.
+func readVint(r io.Reader, maxLen int) (uint64, int, error) {
+	var first [1]byte
+	if _, err := io.ReadFull(r, first[:]); err != nil {
+		return 0, 0, err
+	}
+
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > maxLen {
+		return 0, 0, errors.New("invalid ebml vint")
+	}
+
+	val := uint64(first[0]) & (1<<(8-length) - 1)
+	if length > 1 {
+		rest := make([]byte, length-1)
+		if _, err := io.ReadFull(r, rest); err != nil {
+			return 0, 0, err
+		}
+		for _, b := range rest {
+			val = val<<8 | uint64(b)
+		}
+	}
+	return val, length, nil
+}
@@ -0,0 +1,84 @@
+package exifdate
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+)
+
+// samsungVideoMarker precedes the MP4 in Samsung motion photos.
+var samsungVideoMarker = []byte("MotionPhoto_Data")
+
+// MotionPhotoOffset returns the position of the MP4 video embedded in a Motion Photo JPEG
+// (Google "MP"/"MVIMG" files, Samsung motion photos).
+// It returns 0 if r is not a motion photo or the video can't be located.
+func MotionPhotoOffset(r io.ReadSeeker) (int64, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	sig, err := sniff(r)
+	if err != nil || !isJPEG(sig) {
+		return 0, err
+	}
+
+	_, xmp, err := scanJPEG(r, true)
+	if xmp == nil {
+		return 0, err
+	}
+	if xmpValue(xmp, "GCamera:MotionPhoto") != "1" && xmpValue(xmp, "GCamera:MicroVideo") != "1" {
+		return 0, nil
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	// The video is always at the end of the file: older files store its distance
+	// from the end, newer ones describe it as the last item of a Container directory.
+	length, _ := strconv.ParseInt(xmpValue(xmp, "GCamera:MicroVideoOffset"), 10, 64)
+	if length == 0 {
+		length = motionItemLength(xmp)
+	}
+	if length <= 0 || length >= size {
+		return 0, nil
+	}
+	offset := size - length
+
+	var head [8]byte
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if _, err := io.ReadFull(r, head[:]); err == nil && string(head[4:8]) == "ftyp" {
+		return offset, nil
+	}
+
+	// Samsung appends its own trailer after the video, so the length is counted
+	// from a slightly wrong place. Look for the marker in front of the video instead.
+	start := max(offset-64*1024, 0)
+	buf := make([]byte, offset+int64(len(samsungVideoMarker))-start)
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, _ := io.ReadFull(r, buf)
+	if i := bytes.LastIndex(buf[:n], samsungVideoMarker); i >= 0 {
+		return start + int64(i+len(samsungVideoMarker)), nil
+	}
+	return 0, nil
+}
+
+// motionItemLength returns Item:Length of the Container item with the MotionPhoto semantic.
+func motionItemLength(xmp []byte) int64 {
+	i := bytes.Index(xmp, []byte(`Item:Semantic="MotionPhoto"`))
+	if i < 0 {
+		return 0
+	}
+	// Attributes of the same element, between its opening '<' and closing '>'
+	start := bytes.LastIndexByte(xmp[:i], '<')
+	end := bytes.IndexByte(xmp[i:], '>')
+	if start < 0 || end < 0 {
+		return 0
+	}
+	n, _ := strconv.ParseInt(xmpValue(xmp[start:i+end], "Item:Length"), 10, 64)
+	return n
+}
@@ -0,0 +1,224 @@
+package exifdate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Seconds between the QuickTime epoch (1904-01-01 UTC) and the Unix epoch.
+const qtEpochOffset = 2082844800
+
+// keyCreationDate is written by Apple devices into 'moov/meta'.
+// Unlike mvhd, it holds the local capture time together with its UTC offset.
+const keyCreationDate = "com.apple.quicktime.creationdate"
+
+var quickTimeLayouts = []string{
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05Z07:00",
+}
+
+// isQuickTime reports whether the signature looks like an ISO-BMFF / QuickTime movie.
+// Modern files start with 'ftyp', but old QuickTime files may start directly with other atoms.
+func isQuickTime(sig []byte) bool {
+	switch string(sig[4:8]) {
+	case "ftyp":
+		return !isHEIC(sig) && !isCR3(sig)
+	case "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+// ExtractQuickTimeDate reads the movie creation time.
+// It prefers the Apple creationdate key (local time with offset) and falls back
+// to the 'moov/mvhd' atom. The mvhd value is stored as UTC, so that time is returned in UTC.
+func ExtractQuickTimeDate(r io.ReadSeeker) (time.Time, error) {
+	moov, err := findBox(r, 0, ^uint64(0), "moov")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: moov box not found: %v", ErrUnsupported, err)
+	}
+	moovEnd := moov.dataOffset + moov.dataSize
+
+	if meta, err := findBox(r, moov.dataOffset, moovEnd, "meta"); err == nil {
+		if val, err := readQuickTimeKey(r, meta, keyCreationDate); err == nil && val != "" {
+			if t, err := parseQuickTimeDate(val); err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	mvhd, err := findBox(r, moov.dataOffset, moovEnd, "mvhd")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: mvhd box not found: %v", ErrUnsupported, err)
+	}
+
+	return parseMvhd(r, mvhd)
+}
+
+// parseMvhd reads creation_time from the 'mvhd' FullBox.
+// Version 0 stores it as 32-bit, version 1 as 64-bit seconds since 1904.
+func parseMvhd(r io.ReadSeeker, mvhd boxHeader) (time.Time, error) {
+	if mvhd.dataSize < 12 {
+		return time.Time{}, fmt.Errorf("%w: mvhd too small", ErrUnsupported)
+	}
+	if _, err := r.Seek(int64(mvhd.dataOffset), io.SeekStart); err != nil {
+		return time.Time{}, err
+	}
+
+	// Version (1) + Flags (3) + creation_time (4 or 8)
+	var buf [12]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return time.Time{}, err
+	}
+
+	var secs uint64
+	switch buf[0] {
+	case 0:
+		secs = uint64(binary.BigEndian.Uint32(buf[4:8]))
+	case 1:
+		secs = binary.BigEndian.Uint64(buf[4:12])
+	default:
+		return time.Time{}, fmt.Errorf("%w: unknown mvhd version %d", ErrUnsupported, buf[0])
+	}
+
+	if secs == 0 {
+		// Same as an empty EXIF date: the file simply has no date, ExifTool won't find one either.
+		return time.Time{}, errors.New("date not set")
+	}
+
+	return time.Unix(int64(secs)-qtEpochOffset, 0).UTC(), nil
+}
+
+// readQuickTimeKey returns the string value of a 'mdta' key from the 'meta/keys' + 'meta/ilst' atoms.
+// Keys are listed in 'keys'; 'ilst' children are named by the 1-based index of their key.
+func readQuickTimeKey(r io.ReadSeeker, meta boxHeader, key string) (string, error) {
+	start, err := metaChildrenOffset(r, meta)
+	if err != nil {
+		return "", err
+	}
+	end := meta.dataOffset + meta.dataSize
+
+	keys, err := findBox(r, start, end, "keys")
+	if err != nil {
+		return "", err
+	}
+	index, err := findKeyIndex(r, keys, key)
+	if err != nil {
+		return "", err
+	}
+
+	ilst, err := findBox(r, start, end, "ilst")
+	if err != nil {
+		return "", err
+	}
+
+	var value string
+	err = scanBoxes(r, ilst.dataOffset, ilst.dataOffset+ilst.dataSize, func(item boxHeader) (bool, error) {
+		if binary.BigEndian.Uint32([]byte(item.typ)) != index {
+			return false, nil
+		}
+		data, err := findBox(r, item.dataOffset, item.dataOffset+item.dataSize, "data")
+		if err != nil {
+			return true, err
+		}
+		value, err = readDataAtom(r, data)
+		return true, err
+	})
+	return value, err
+}
+
+// metaChildrenOffset returns where the children of a 'meta' box start.
+// In MP4 'meta' is a FullBox (4 bytes Version + Flags), in QuickTime it is a plain box.
+func metaChildrenOffset(r io.ReadSeeker, meta boxHeader) (uint64, error) {
+	if meta.dataSize < 8 {
+		return 0, errors.New("meta too small")
+	}
+	if _, err := r.Seek(int64(meta.dataOffset), io.SeekStart); err != nil {
+		return 0, err
+	}
+	var vf [4]byte
+	if _, err := io.ReadFull(r, vf[:]); err != nil {
+		return 0, err
+	}
+	// A child box can't have size 0 here, so zeroes mean Version + Flags.
+	if binary.BigEndian.Uint32(vf[:]) == 0 {
+		return meta.dataOffset + 4, nil
+	}
+	return meta.dataOffset, nil
+}
+
+// findKeyIndex parses the 'keys' FullBox: [4 bytes Ver+Flags] + [4 bytes Count] + [Size, Namespace, Name]...
+func findKeyIndex(r io.ReadSeeker, keys boxHeader, key string) (uint32, error) {
+	if keys.dataSize < 8 {
+		return 0, errors.New("keys too small")
+	}
+	if _, err := r.Seek(int64(keys.dataOffset), io.SeekStart); err != nil {
+		return 0, err
+	}
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, err
+	}
+	count := binary.BigEndian.Uint32(hdr[4:8])
+
+	remaining := keys.dataSize - 8
+	for i := uint32(1); i <= count; i++ {
+		// Size (4) + Namespace (4)
+		if remaining < 8 {
+			break
+		}
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return 0, err
+		}
+		size := uint64(binary.BigEndian.Uint32(hdr[0:4]))
+		if size < 8 || size > remaining {
+			return 0, errors.New("invalid key size")
+		}
+		remaining -= size
+
+		nameLen := int64(size - 8)
+		if nameLen != int64(len(key)) {
+			if _, err := r.Seek(nameLen, io.SeekCurrent); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(r, name); err != nil {
+			return 0, err
+		}
+		if string(name) == key {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("key %s not found", key)
+}
+
+// readDataAtom reads a 'data' atom: [4 bytes Type] + [4 bytes Locale] + Value.
+func readDataAtom(r io.ReadSeeker, data boxHeader) (string, error) {
+	// Dates are short; anything bigger is not what we are looking for.
+	if data.dataSize < 8 || data.dataSize > 8+256 {
+		return "", errors.New("unexpected data atom size")
+	}
+	if _, err := r.Seek(int64(data.dataOffset), io.SeekStart); err != nil {
+		return "", err
+	}
+	buf := make([]byte, data.dataSize)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(bytes.TrimRight(buf[8:], "\x00 ")), nil
+}
+
+func parseQuickTimeDate(s string) (time.Time, error) {
+	for _, layout := range quickTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: unknown date format '%s'", ErrUnsupported, s)
+}
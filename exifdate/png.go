@@ -0,0 +1,201 @@
+package exifdate
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+var pngSignature = []byte{0x89, 0x50, 0x4E, 0x47}
+
+// Textual dates found in PNG "Creation Time" chunks. The spec recommends RFC 1123,
+// but EXIF-style and ISO values are common as well (see parseTextDate).
+var pngTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+}
+
+func isPNG(sig []byte) bool {
+	return bytes.HasPrefix(sig, pngSignature)
+}
+
+// extractPNG walks through PNG chunks looking for the "eXIf" chunk.
+func extractPNG(r io.Reader) ([]byte, error) {
+	blob, _, err := scanPNG(r)
+	return blob, err
+}
+
+// pngMetadata prefers the eXIf chunk and falls back to textual dates.
+// Screenshots and exports often have no EXIF at all, but do carry XMP or "Creation Time".
+func pngMetadata(r io.Reader, full bool) (Metadata, error) {
+	blob, text, err := scanPNG(r)
+	if err != nil {
+		return Metadata{}, err
+	}
+	md, err := parseWithFallback(blob, "PNG text", text.date(), full)
+	if md.Software == "" {
+		md.Software = text.software
+	}
+	if md.UserComment == "" {
+		md.UserComment = text.comment
+	}
+	return md, err
+}
+
+// pngText is what the text chunks of a PNG tell.
+type pngText struct {
+	xmpDate, creationTime string
+	software              string // "Software" keyword, e.g. gnome-screenshot
+	comment               string // exif:UserComment of the XMP packet, "Screenshot" on macOS and iOS
+}
+
+// date is the best textual date: XMP first, then "Creation Time".
+func (t pngText) date() string {
+	if t.xmpDate != "" {
+		return t.xmpDate
+	}
+	return t.creationTime
+}
+
+// scanPNG walks through PNG chunks and returns the "eXIf" payload (if any)
+// and the text chunks seen before it.
+func scanPNG(r io.Reader) ([]byte, pngText, error) {
+	var text pngText
+	if _, err := io.CopyN(io.Discard, r, 8); err != nil {
+		return nil, text, err
+	}
+
+	// Buffer for Length (4 bytes) and Type (4 bytes)
+	header := make([]byte, 8)
+
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return nil, text, nil // End of file, no EXIF found
+			}
+			return nil, text, err
+		}
+
+		length := binary.BigEndian.Uint32(header[0:4])
+		chunkType := string(header[4:8])
+
+		switch chunkType {
+		case "eXIf":
+			// Sanity check: EXIF shouldn't be massive (usually < 64KB)
+			// Limit to 10MB to prevent OOM attacks
+			if length > 10*1024*1024 {
+				return nil, text, errors.New("exif data too large")
+			}
+
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, text, err
+			}
+			// Note: PNG eXIf chunks contain the raw TIFF structure (II/MM...)
+			// They usually do NOT have the "Exif\0\0" header that JPEG has,
+			// so we return the data as-is.
+			return data, text, nil
+
+		case "tEXt", "zTXt", "iTXt":
+			// Text chunks are small; XMP packets can reach a few hundred KB.
+			if length <= 1024*1024 {
+				data := make([]byte, length+4) // Payload + CRC
+				if _, err := io.ReadFull(r, data); err != nil {
+					return nil, text, err
+				}
+				keyword, value := parseTextChunk(chunkType, data[:length])
+				switch keyword {
+				case "Creation Time":
+					text.creationTime = string(bytes.TrimSpace(value))
+				case "Software":
+					text.software = string(bytes.TrimSpace(value))
+				case "XML:com.adobe.xmp":
+					text.xmpDate = xmpDate(value)
+					if c := xmpAltValue(value, "exif:UserComment"); c != "" {
+						text.comment = c
+					}
+				}
+				continue
+			}
+
+		case "IEND":
+			return nil, text, nil
+		}
+
+		skipAmount := int64(length) + 4 // Skip Payload + CRC
+		if _, err := io.CopyN(io.Discard, r, skipAmount); err != nil {
+			return nil, text, err
+		}
+	}
+}
+
+// parseTextChunk returns the keyword and the (decompressed) text of a tEXt, zTXt or iTXt chunk.
+//
+//	tEXt: Keyword \0 Text
+//	zTXt: Keyword \0 Method(1) zlib(Text)
+//	iTXt: Keyword \0 Compressed(1) Method(1) Language \0 TranslatedKeyword \0 Text
+func parseTextChunk(chunkType string, data []byte) (string, []byte) {
+	idx := bytes.IndexByte(data, 0)
+	if idx < 0 {
+		return "", nil
+	}
+	keyword := string(data[:idx])
+	rest := data[idx+1:]
+
+	compressed := false
+	switch chunkType {
+	case "zTXt":
+		if len(rest) < 1 {
+			return keyword, nil
+		}
+		rest = rest[1:]
+		compressed = true
+	case "iTXt":
+		if len(rest) < 2 {
+			return keyword, nil
+		}
+		compressed = rest[0] == 1
+		rest = rest[2:]
+		// Skip Language and Translated Keyword
+		for range 2 {
+			i := bytes.IndexByte(rest, 0)
+			if i < 0 {
+				return keyword, nil
+			}
+			rest = rest[i+1:]
+		}
+	}
+
+	if !compressed {
+		return keyword, rest
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return keyword, nil
+	}
+	defer zr.Close()
+	text, err := io.ReadAll(io.LimitReader(zr, 1024*1024))
+	if err != nil {
+		return keyword, nil
+	}
+	return keyword, text
+}
+
+// parseTextDate parses free-form dates found in text metadata (PNG chunks, XMP).
+func parseTextDate(s string) (time.Time, error) {
+	if t, err := parseXMPDate(s); err == nil {
+		return t, nil
+	}
+	for _, layout := range pngTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return parseExifTime(s)
+}
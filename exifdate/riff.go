@@ -0,0 +1,116 @@
+package exifdate
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Camcorders write dates in many shapes: "SAT DEC 21 11:34:28 2002" in IDIT,
+// EXIF-like strings, or just "2002-12-21" in ICRD.
+var riffLayouts = []string{
+	time.ANSIC,
+	"Mon Jan 2 15:04:05 2006",
+	"2006:01:02 15:04:05",
+	"2006-01-02 15:04:05",
+	"2006/01/02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006:01:02",
+	"2006-01-02",
+}
+
+func isAVI(sig []byte) bool {
+	return string(sig[0:4]) == "RIFF" && string(sig[8:12]) == "AVI "
+}
+
+// ExtractAVIDate reads the capture date from a RIFF/AVI file.
+// It prefers the IDIT chunk (inside LIST hdrl) over ICRD (inside LIST INFO).
+func ExtractAVIDate(r io.ReadSeeker) (time.Time, error) {
+	var idit, icrd string
+
+	_, err := walkRIFF(r, 12, ^uint32(0), func(id string, size uint32) (bool, error) {
+		if id != "IDIT" && id != "ICRD" {
+			return false, nil
+		}
+		// Date strings are short; ignore anything suspicious.
+		if size > 256 {
+			return false, nil
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return false, err
+		}
+		val := strings.TrimSpace(string(bytes.TrimRight(buf, "\x00")))
+		if id == "IDIT" {
+			idit = val
+			return true, nil
+		}
+		icrd = val
+		return false, nil
+	})
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return time.Time{}, err
+	}
+
+	for _, val := range []string{idit, icrd} {
+		if val == "" {
+			continue
+		}
+		for _, layout := range riffLayouts {
+			if t, err := time.ParseInLocation(layout, val, time.Local); err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	if idit == "" && icrd == "" {
+		return time.Time{}, fmt.Errorf("%w: no date chunk in avi", ErrUnsupported)
+	}
+	return time.Time{}, fmt.Errorf("%w: unknown date format '%s%s'", ErrUnsupported, idit, icrd)
+}
+
+// walkRIFF iterates chunks between start and end, descending into LIST hdrl and LIST INFO.
+// The callback is positioned at the chunk payload and returns true to stop the walk.
+func walkRIFF(r io.ReadSeeker, start, end uint32, cb func(id string, size uint32) (bool, error)) (bool, error) {
+	var hdr [12]byte
+	pos := start
+
+	for end-pos >= 8 {
+		if _, err := r.Seek(int64(pos), io.SeekStart); err != nil {
+			return false, err
+		}
+		if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+			return false, err
+		}
+		id := string(hdr[0:4])
+		size := binary.LittleEndian.Uint32(hdr[4:8])
+		next := pos + 8 + size + size&1 // Chunks are padded to an even size
+		if next < pos {
+			return false, nil // Overflow: corrupt size
+		}
+
+		if id == "LIST" && size >= 4 {
+			if _, err := io.ReadFull(r, hdr[8:12]); err != nil {
+				return false, err
+			}
+			// Skip 'movi' (the actual video) and other lists we don't care about
+			if listType := string(hdr[8:12]); listType == "hdrl" || listType == "INFO" {
+				done, err := walkRIFF(r, pos+12, pos+8+size, cb)
+				if err != nil || done {
+					return done, err
+				}
+			}
+		} else {
+			done, err := cb(id, size)
+			if err != nil || done {
+				return done, err
+			}
+		}
+
+		pos = next
+	}
+	return false, nil
+}
@@ -0,0 +1,105 @@
+package exifdate
+
+import (
+	"bytes"
+	"errors"
+	"time"
+)
+
+// xmpDateTags are checked in order of preference.
+var xmpDateTags = []string{
+	"exif:DateTimeOriginal",
+	"photoshop:DateCreated",
+	"xmp:CreateDate",
+}
+
+// XMP dates are ISO 8601 with optional seconds, fractions and zone.
+// Values without a zone are local time, same as EXIF.
+var xmpLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseXMP returns the capture date from an XMP packet, e.g. the content of a .xmp sidecar file.
+func ParseXMP(packet []byte) (time.Time, error) {
+	s := xmpDate(packet)
+	if s == "" {
+		return time.Time{}, errors.New("no date found in xmp")
+	}
+	return parseTextDate(s)
+}
+
+// xmpDate returns the raw value of the first date property found in an XMP packet.
+func xmpDate(packet []byte) string {
+	for _, name := range xmpDateTags {
+		if v := xmpValue(packet, name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// xmpValue finds a simple property without a full XML parser.
+// XMP stores it either as an attribute (xmp:CreateDate="...")
+// or as an element (<xmp:CreateDate>...</xmp:CreateDate>).
+func xmpValue(packet []byte, name string) string {
+	for _, quote := range []byte{'"', '\''} {
+		attr := []byte(name + "=" + string(quote))
+		if i := bytes.Index(packet, attr); i >= 0 {
+			rest := packet[i+len(attr):]
+			if j := bytes.IndexByte(rest, quote); j >= 0 {
+				return string(bytes.TrimSpace(rest[:j]))
+			}
+		}
+	}
+
+	elem := []byte("<" + name + ">")
+	if i := bytes.Index(packet, elem); i >= 0 {
+		rest := packet[i+len(elem):]
+		if j := bytes.IndexByte(rest, '<'); j >= 0 {
+			return string(bytes.TrimSpace(rest[:j]))
+		}
+	}
+	return ""
+}
+
+// xmpAltValue is xmpValue for a language alternative (<rdf:Alt><rdf:li xml:lang="x-default">...</rdf:li></rdf:Alt>),
+// as used for descriptions and comments. It returns the first alternative.
+func xmpAltValue(packet []byte, name string) string {
+	if v := xmpValue(packet, name); v != "" {
+		return v
+	}
+	elem := []byte("<" + name + ">")
+	i := bytes.Index(packet, elem)
+	if i < 0 {
+		return ""
+	}
+	rest := packet[i+len(elem):]
+	end := bytes.Index(rest, []byte("</"+name+">"))
+	li := bytes.Index(rest, []byte("<rdf:li"))
+	if end < 0 || li < 0 || li > end {
+		return ""
+	}
+	rest = rest[li:end]
+	start := bytes.IndexByte(rest, '>')
+	if start < 0 {
+		return ""
+	}
+	rest = rest[start+1:]
+	if j := bytes.IndexByte(rest, '<'); j >= 0 {
+		rest = rest[:j]
+	}
+	return string(bytes.TrimSpace(rest))
+}
+
+func parseXMPDate(s string) (time.Time, error) {
+	for _, layout := range xmpLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, errors.New("not an xmp date")
+}
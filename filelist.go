@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// readFileList sends the files listed in name ("-" for stdin) instead of walking a source.
+// Paths are separated by newlines or NUL bytes, so both `find` and `find -print0` work.
+// The same filters as for a walked source apply.
+func readFileList(ctx context.Context, name string, files chan<- sourceFile) {
+	var r io.Reader = os.Stdin
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			log.Error("Failed to read file list: %v", err)
+			return
+		}
+		defer f.Close()
+		r = f
+	}
+
+	feed := feeder{ctx: ctx, files: files}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64*1024)
+	sc.Split(splitPaths)
+	for sc.Scan() {
+		path := string(bytes.TrimSuffix(sc.Bytes(), []byte("\r")))
+		if path == "" {
+			continue
+		}
+		if excluded(filepath.ToSlash(path)) || !wantName(path, filepath.ToSlash(path)) {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			log.Warn("Skipping %s: %v", path, err)
+			continue
+		}
+		if info.IsDir() {
+			log.Warn("Skipping %s: is a directory", path)
+			continue
+		}
+
+		if wantFile(path, info) && !feed.send(sourceFile{Path: path, Info: info, Rel: filepath.ToSlash(path)}) {
+			return
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Error("Failed to read file list: %v", err)
+	}
+}
+
+// splitPaths is bufio.ScanLines for lists separated by either '\n' or '\0'.
+func splitPaths(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if i := bytes.IndexAny(data, "\n\x00"); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
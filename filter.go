@@ -0,0 +1,77 @@
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// systemExcludes are folders and files created by operating systems and NAS software,
+// skipped unless --skip-system=false.
+var systemExcludes = []string{
+	"@eaDir", "#recycle", "#snapshot", // Synology
+	".@__thumb", "@Recycle", // QNAP
+	".thumbnails", ".Trash-*", "lost+found", // Linux
+	".Trashes", ".Spotlight-V100", ".fseventsd", ".AppleDouble", "._*", ".DS_Store", // macOS
+	"$RECYCLE.BIN", "System Volume Information", "Thumbs.db", "desktop.ini", // Windows
+	metaDir, // Our own journal and index, when importing from another library
+}
+
+// excluded reports whether rel is skipped by --exclude or the system list.
+func excluded(rel string) bool {
+	return matchAny(cfg.Exclude, rel) || cfg.SkipSystem && matchAny(systemExcludes, rel)
+}
+
+// addPattern validates a --include/--exclude glob and appends it to list.
+func addPattern(list *[]string, pattern string) error {
+	pattern = strings.Trim(filepath.ToSlash(pattern), "/")
+	for seg := range strings.SplitSeq(pattern, "/") {
+		if _, err := path.Match(seg, ""); err != nil {
+			return err
+		}
+	}
+	*list = append(*list, pattern)
+	return nil
+}
+
+// matchAny reports whether rel, a slash-separated path relative to the source, matches one of the patterns.
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if matchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches like path.Match, with two additions known from .gitignore:
+// "**" stands for any number of directories, and a pattern without a slash
+// is matched against the base name only ("*_edited*").
+func matchGlob(pattern, rel string) bool {
+	if !strings.Contains(pattern, "/") && pattern != "**" {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
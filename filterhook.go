@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// FilterHook is the --filter-hook program. It is started once and asked about every file in turn:
+// exisort writes a "path<TAB>date<TAB>size<TAB>date source" line to its stdin and reads one answer
+// line, "accept" (or "yes", "y", "1") to import the file, anything else to skip it. See hookPath
+// for paths that contain tabs or newlines.
+type FilterHook struct {
+	mu     sync.Mutex
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	wait   func() error
+	failed bool // The program quit or misbehaved; every file after that is skipped
+}
+
+var filterHook *FilterHook
+
+// StartFilterHook starts command through the shell.
+func StartFilterHook(command string) (*FilterHook, error) {
+	cmd := shellCommand(command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &FilterHook{stdin: stdin, stdout: bufio.NewReader(stdout), wait: cmd.Wait}, nil
+}
+
+// Want asks the program whether to import the file. Without a hook every file is wanted.
+// If the program fails, the file is skipped and counted as an error rather than imported unchecked.
+func (h *FilterHook) Want(path string, d DateInfo, size int64) bool {
+	if h == nil {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.failed {
+		stats.IncError()
+		return false
+	}
+	answer, err := h.ask(fmt.Sprintf("%s\t%s\t%d\t%s\n", hookPath(path), d.Time.Format(time.RFC3339), size, d.Source))
+	if err != nil {
+		h.failed = true
+		stats.IncError()
+		log.Error("Filter hook failed, skipping all further files: %v", err)
+		return false
+	}
+	switch strings.ToLower(answer) {
+	case "accept", "yes", "y", "1":
+		return true
+	}
+	log.Info("Skipping %s: rejected by the filter hook", path)
+	return false
+}
+
+// hookPath writes a path for the hook. A path with a control character, such as a tab or newline that
+// would break the line, or starting with a double quote is quoted with Go (and C) escapes: "a\tb.jpg".
+// Other paths, Windows ones included, are written as they are.
+func hookPath(path string) string {
+	if strings.HasPrefix(path, `"`) || strings.ContainsFunc(path, unicode.IsControl) {
+		return strconv.Quote(path)
+	}
+	return path
+}
+
+func (h *FilterHook) ask(line string) (string, error) {
+	if _, err := io.WriteString(h.stdin, line); err != nil {
+		return "", err
+	}
+	answer, err := h.stdout.ReadString('\n')
+	if err == io.EOF {
+		return "", errors.New("the program exited")
+	}
+	return strings.TrimSpace(answer), err
+}
+
+// Close ends the input of the program and waits for it to exit.
+func (h *FilterHook) Close() error {
+	if h == nil {
+		return nil
+	}
+	h.stdin.Close()
+	return h.wait()
+}
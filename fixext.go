@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/levmv/exisort/exifdate"
+)
+
+// extFamilies maps the formats reported by exifdate.Detect to the extensions that are fine for them.
+// Detect can't tell NEF, ARW or DNG from a plain TIFF, so all TIFF based RAWs count as "tif".
+var extFamilies = map[string][]string{
+	"jpg":  {"jpg", "jpeg", "jpe"},
+	"heic": {"heic", "heif", "heics", "hif"},
+	"avif": {"avif", "avifs"},
+	"png":  {"png"},
+	"jxl":  {"jxl"},
+	"cr3":  {"cr3"},
+	"cr2":  {"cr2"},
+	"orf":  {"orf", "ors"},
+	"rw2":  {"rw2", "raw", "rwl"},
+	"tif":  {"tif", "tiff", "nef", "nrw", "arw", "srf", "sr2", "dng", "pef", "srw", "3fr", "erf", "mef", "mos", "iiq", "kdc", "dcr"},
+	"mov":  {"mov", "qt", "mp4", "m4v", "3gp", "3g2"},
+	"mp4":  {"mp4", "m4v", "3gp", "3g2", "mov"},
+	"avi":  {"avi"},
+	"mkv":  {"mkv", "webm"},
+	"webm": {"webm", "mkv"},
+}
+
+// fixedExt returns the extension path should have according to its content (--fix-ext),
+// e.g. "heic" for a HEIC named ".jpg", or "" if the current one is fine or the format is unknown.
+// Upper case names stay upper case.
+func fixedExt(path string, head []byte) string {
+	detected := exifdate.Detect(head)
+	if detected == "" {
+		return ""
+	}
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	for _, ok := range extFamilies[detected] {
+		if strings.EqualFold(ext, ok) {
+			return ""
+		}
+	}
+	if ext != "" && ext == strings.ToUpper(ext) {
+		return strings.ToUpper(detected)
+	}
+	return detected
+}
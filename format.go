@@ -0,0 +1,293 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Extensions of the files --format-video and --format-raw apply to, and the {type} token tells apart.
+var (
+	videoExts    = []string{"mov", "qt", "mp4", "m4v", "3gp", "3g2", "avi", "mkv", "webm", "mts", "m2ts"}
+	rawImageExts = []string{"cr2", "cr3", "crw", "nef", "nrw", "arw", "srf", "sr2", "dng", "orf", "ors", "rw2", "raw", "rwl",
+		"pef", "srw", "raf", "3fr", "erf", "mef", "mos", "iiq", "kdc", "dcr", "x3f"}
+)
+
+// mediaType classifies a file by its extension: video, raw or photo.
+func mediaType(name string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	switch {
+	case slices.Contains(videoExts, ext):
+		return "video"
+	case slices.Contains(rawImageExts, ext):
+		return "raw"
+	}
+	return "photo"
+}
+
+// formatFor picks the --format for job, named name: --format-screenshot for screenshots,
+// the format of its camera's --profiles entry, or --format-video or --format-raw if given.
+func formatFor(name string, job FileJob) string {
+	if job.Screenshot {
+		return cfg.FormatShot
+	}
+	if p := profileFor(job.Meta); p != nil && p.Format != "" {
+		return p.Format
+	}
+	switch mediaType(name) {
+	case "video":
+		if cfg.FormatVideo != "" {
+			return cfg.FormatVideo
+		}
+	case "raw":
+		if cfg.FormatRaw != "" {
+			return cfg.FormatRaw
+		}
+	}
+	return cfg.Format
+}
+
+// formatPath fills in the tokens of fmtStr for job, named path, at time t.
+func formatPath(fmtStr string, t time.Time, path string, job FileJob) string {
+	return expandFormat(fmtStr, formatTokens(t, path, job))
+}
+
+// formatTokens gives the value of every --format token for job.
+func formatTokens(t time.Time, path string, job FileJob) map[string]string {
+	_, file := filepath.Split(path)
+	ext := filepath.Ext(file)
+	name := strings.TrimSuffix(file, ext)
+	if len(ext) > 0 {
+		ext = ext[1:] // remove dot
+	}
+
+	// The folders below the source, made safe: archives and file lists may contain ".." or absolute paths
+	var dirs []string
+	if i := strings.LastIndex(job.Rel, "/"); i >= 0 {
+		for _, d := range strings.Split(job.Rel[:i], "/") {
+			if d = cleanToken(d); d != "" {
+				dirs = append(dirs, d)
+			}
+		}
+	}
+	var album string
+	if len(dirs) > 0 {
+		album = dirs[len(dirs)-1]
+	}
+
+	var city, country string
+	if job.Meta.HasGPS && (formatUses("city") || formatUses("country")) {
+		city, country = locate(job.Meta.Latitude, job.Meta.Longitude)
+	}
+
+	weekYear, week := t.ISOWeek()
+	monthName := t.Month().String()
+	if cfg.MonthNames != nil {
+		monthName = cfg.MonthNames[t.Month()-1]
+	}
+
+	// Use t.Format for everything. It's cleaner.
+	return map[string]string{
+		"year":       t.Format("2006"),
+		"month":      t.Format("01"),
+		"day":        t.Format("02"),
+		"hour":       t.Format("15"),
+		"min":        t.Format("04"),
+		"sec":        t.Format("05"),
+		"subsec":     fmt.Sprintf("%03d", t.Nanosecond()/int(time.Millisecond)),
+		"counter":    fmt.Sprintf("%04d", job.Counter),
+		"week":       fmt.Sprintf("%02d", week),
+		"weekyear":   strconv.Itoa(weekYear),
+		"quarter":    strconv.Itoa((int(t.Month()) + 2) / 3),
+		"monthname":  cleanToken(monthName),
+		"weekday":    t.Weekday().String(),
+		"doy":        fmt.Sprintf("%03d", t.YearDay()),
+		"filename":   name,
+		"ext":        ext,
+		"type":       mediaType(file),
+		"datesource": job.DateSource,
+		"session":    cfg.Tag,
+		"relpath":    filepath.Join(dirs...),
+		"album":      album,
+		"srcdir":     cleanToken(sourceName),
+		"make":       cleanToken(job.Meta.Make),
+		"model":      cleanToken(job.Meta.Model),
+		"lens":       cleanToken(job.Meta.LensModel),
+		"software":   cleanToken(job.Meta.Software),
+		"artist":     cleanToken(cmp.Or(job.Meta.Artist, job.Meta.OwnerName)),
+		"serial":     cleanToken(job.Meta.Serial),
+		"iso":        isoToken(job.Meta.ISO),
+		"fnumber":    fnumberToken(job.Meta.FNumber),
+		"city":       cleanToken(city),
+		"country":    cleanToken(country),
+	}
+}
+
+// expandFormat replaces the {token} placeholders of fmtStr. A token can be followed by filters,
+// applied left to right: {model|default:Unknown}, {make|lower}, {year|slice:2}.
+// Braces around anything but a token are kept as they are.
+func expandFormat(fmtStr string, tokens map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(fmtStr, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(fmtStr[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+
+		b.WriteString(fmtStr[:start])
+		if v, err := expandToken(fmtStr[start+1:end], tokens); err == nil {
+			b.WriteString(v)
+		} else {
+			b.WriteString(fmtStr[start : end+1])
+		}
+		fmtStr = fmtStr[end+1:]
+	}
+	b.WriteString(fmtStr)
+	return b.String()
+}
+
+// expandToken gives the value of a placeholder such as "model|default:Unknown|upper".
+func expandToken(expr string, tokens map[string]string) (string, error) {
+	name, filters, _ := strings.Cut(expr, "|")
+	v, ok := tokens[name]
+	if !ok {
+		return "", fmt.Errorf("unknown token {%s}", name)
+	}
+	if filters == "" {
+		return v, nil
+	}
+	for f := range strings.SplitSeq(filters, "|") {
+		var err error
+		if v, err = applyFilter(v, f); err != nil {
+			return "", fmt.Errorf("{%s}: %w", expr, err)
+		}
+	}
+	return v, nil
+}
+
+// applyFilter transforms a token value:
+//
+//	default:text   text if the value is empty
+//	lower, upper   change the case
+//	slice:from:to  the characters from..to, counted from the end if negative; to is optional
+func applyFilter(v, filter string) (string, error) {
+	name, arg, _ := strings.Cut(filter, ":")
+	switch name {
+	case "default":
+		if v == "" {
+			return cleanToken(arg), nil
+		}
+		return v, nil
+	case "lower":
+		return strings.ToLower(v), nil
+	case "upper":
+		return strings.ToUpper(v), nil
+	case "slice":
+		fromArg, toArg, hasTo := strings.Cut(arg, ":")
+		n := utf8.RuneCountInString(v)
+		from, err := sliceIndex(fromArg, n)
+		if err != nil {
+			return "", err
+		}
+		to := n
+		if hasTo {
+			if to, err = sliceIndex(toArg, n); err != nil {
+				return "", err
+			}
+		}
+		if from >= to {
+			return "", nil
+		}
+		return string([]rune(v)[from:to]), nil
+	}
+	return "", fmt.Errorf("unknown filter %q", name)
+}
+
+// sliceIndex parses a slice bound for a value of n characters, clamped to 0..n.
+func sliceIndex(s string, n int) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad slice bound %q", s)
+	}
+	if i < 0 {
+		i += n
+	}
+	return min(max(i, 0), n), nil
+}
+
+// checkFormat reports placeholders with filters that can't be applied, e.g. a misspelled filter,
+// so that the mistake shows before the import rather than in the names. Plain braces are allowed.
+func checkFormat(fmtStr string) error {
+	tokens := formatTokens(time.Now(), "name.ext", FileJob{})
+	for {
+		start := strings.IndexByte(fmtStr, '{')
+		if start < 0 {
+			return nil
+		}
+		end := strings.IndexByte(fmtStr[start:], '}')
+		if end < 0 {
+			return nil
+		}
+		if expr := fmtStr[start+1 : start+end]; strings.Contains(expr, "|") {
+			if _, err := expandToken(expr, tokens); err != nil {
+				return err
+			}
+		}
+		fmtStr = fmtStr[start+end+1:]
+	}
+}
+
+// formatUses reports whether any of the formats contains the token name, with or without filters.
+func formatUses(name string) bool {
+	formats := []string{cfg.Format, cfg.FormatVideo, cfg.FormatRaw}
+	if cfg.Screenshots == "separate" {
+		formats = append(formats, cfg.FormatShot)
+	}
+	for _, p := range profiles {
+		formats = append(formats, p.Format)
+	}
+	for _, f := range formats {
+		if strings.Contains(f, "{"+name+"}") || strings.Contains(f, "{"+name+"|") {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanToken makes a metadata value usable in a path: characters that file systems don't allow
+// become "_", and leading or trailing spaces and dots are dropped.
+func cleanToken(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, s)
+	return strings.Trim(s, ". ")
+}
+
+func isoToken(iso int) string {
+	if iso == 0 {
+		return ""
+	}
+	return strconv.Itoa(iso)
+}
+
+// fnumberToken gives the aperture with one decimal at most, "2.8" or "8".
+func fnumberToken(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// placesData lists the urban areas of the world for {city} and {country}, see geodata/README.md.
+//
+//go:embed geodata/places.tsv.gz
+var placesData []byte
+
+const (
+	cityMargin   = 5   // km beyond the edge of an urban area that still count as the city
+	countryReach = 250 // km to the nearest urban area up to which its country is assumed
+)
+
+type place struct {
+	lat, lon, radius float64 // radius in km
+	city, country    string
+}
+
+var places = sync.OnceValue(func() []place {
+	zr, err := gzip.NewReader(bytes.NewReader(placesData))
+	if err != nil {
+		panic(err) // Embedded, so only a broken build gets here
+	}
+	var list []place
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		f := strings.Split(sc.Text(), "\t")
+		if len(f) != 5 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		lat, _ := strconv.ParseFloat(f[0], 64)
+		lon, _ := strconv.ParseFloat(f[1], 64)
+		radius, _ := strconv.ParseFloat(f[2], 64)
+		list = append(list, place{lat: lat, lon: lon, radius: radius, city: f[3], country: f[4]})
+	}
+	return list
+})
+
+// locate reverse geocodes a GPS position offline. The city is the urban area it lies in, the one it is most central to
+// if areas overlap (Vatican City within Rome). The country is that of the city, or else of the nearest urban area,
+// so it can be wrong close to a border.
+func locate(lat, lon float64) (city, country string) {
+	nearest, nearestDist := -1, math.Inf(1)
+	inside, insideScore := -1, math.Inf(1)
+	list := places()
+	for i, p := range list {
+		d := distanceKm(lat, lon, p.lat, p.lon)
+		if d < nearestDist {
+			nearest, nearestDist = i, d
+		}
+		// Distance relative to the size of the area: below 1 is inside
+		if score := d / (p.radius + cityMargin); score <= 1 && score < insideScore {
+			inside, insideScore = i, score
+		}
+	}
+	switch {
+	case inside >= 0:
+		return list[inside].city, list[inside].country
+	case nearest >= 0 && nearestDist <= countryReach:
+		return "", list[nearest].country
+	}
+	return "", ""
+}
+
+// distanceKm is the great-circle distance between two positions.
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371
+	rad := math.Pi / 180
+	dLat, dLon := (lat2-lat1)*rad, (lon2-lon1)*rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -3,3 +3,11 @@ module github.com/levmv/exisort
 go 1.25
 
 require github.com/barasher/go-exiftool v1.10.0
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/zeebo/blake3 v0.2.4
+	golang.org/x/sys v0.36.0
+)
+
+require github.com/klauspost/cpuid/v2 v2.0.12 // indirect
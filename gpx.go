@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+)
+
+// gpxMaxGap is how far apart in time a photo and the track points around it may be.
+// Beyond it the logger was off or had no fix, and the photo is left untagged.
+const gpxMaxGap = 10 * time.Minute
+
+type trackPoint struct {
+	Time     time.Time
+	Lat, Lon float64
+}
+
+// track holds the points of all --gpx files in time order, nil without --gpx.
+var track []trackPoint
+
+type gpxFile struct {
+	Tracks []struct {
+		Segments []struct {
+			Points []struct {
+				Lat  float64   `xml:"lat,attr"`
+				Lon  float64   `xml:"lon,attr"`
+				Time time.Time `xml:"time"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// loadGPX adds the track points of a GPX file to track.
+func loadGPX(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var g gpxFile
+	if err := xml.Unmarshal(data, &g); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	n := len(track)
+	for _, t := range g.Tracks {
+		for _, s := range t.Segments {
+			for _, p := range s.Points {
+				if !p.Time.IsZero() {
+					track = append(track, trackPoint{Time: p.Time, Lat: p.Lat, Lon: p.Lon})
+				}
+			}
+		}
+	}
+	if len(track) == n {
+		return fmt.Errorf("%s: no track points with a time", path)
+	}
+	slices.SortFunc(track, func(a, b trackPoint) int { return a.Time.Compare(b.Time) })
+	return nil
+}
+
+// trackPosition finds where the track was at t, interpolating between the points around it.
+func trackPosition(t time.Time) (lat, lon float64, ok bool) {
+	i, _ := slices.BinarySearchFunc(track, t, func(p trackPoint, t time.Time) int { return p.Time.Compare(t) })
+	var prev, next *trackPoint
+	if i > 0 {
+		prev = &track[i-1]
+	}
+	if i < len(track) {
+		next = &track[i]
+	}
+
+	switch {
+	case prev != nil && next != nil && next.Time.Sub(prev.Time) <= gpxMaxGap:
+		f := 0.0
+		if span := next.Time.Sub(prev.Time); span > 0 {
+			f = float64(t.Sub(prev.Time)) / float64(span)
+		}
+		return prev.Lat + (next.Lat-prev.Lat)*f, prev.Lon + (next.Lon-prev.Lon)*f, true
+	case next != nil && next.Time.Sub(t) <= gpxMaxGap && (prev == nil || t.Sub(prev.Time) > next.Time.Sub(t)):
+		return next.Lat, next.Lon, true
+	case prev != nil && t.Sub(prev.Time) <= gpxMaxGap:
+		return prev.Lat, prev.Lon, true
+	}
+	return 0, 0, false
+}
+
+// geotag gives job the position of the track at its capture time, unless it has one already.
+// Camera times without a zone are taken to be in --gpx-zone, or else the zone of this computer.
+func geotag(job *FileJob) {
+	if track == nil || job.Meta.HasGPS || job.DateSource == "mtime" || job.Date.IsZero() {
+		return
+	}
+	t := job.Date
+	if cfg.GPXZone != nil && t.Location() == time.Local {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), cfg.GPXZone)
+	}
+	lat, lon, ok := trackPosition(t)
+	if !ok {
+		log.Info("No track position for %s at %s", job.Path, t.Format(time.DateTime))
+		return
+	}
+	job.Meta.HasGPS, job.Meta.Latitude, job.Meta.Longitude = true, lat, lon
+	job.Geotagged = true
+	log.Info("Geotagged %s: %.6f, %.6f", job.Path, lat, lon)
+}
+
+// parseZone reads a zone option: a UTC offset such as +02:00, or a zone name such as Europe/Rome.
+func parseZone(v string) (*time.Location, error) {
+	if z, err := time.Parse("-07:00", v); err == nil {
+		_, off := z.Zone()
+		return time.FixedZone(v, off), nil
+	}
+	if loc, err := time.LoadLocation(v); err == nil && v != "" && v != "Local" {
+		return loc, nil
+	}
+	return nil, errors.New("must be an offset like +02:00 or a zone name like Europe/Rome")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"hash"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/zeebo/blake3"
+)
+
+// hashAlgos are the choices of --hash-algo for hashing whole files: SHA-256 by default,
+// xxh64 and blake3 for speed, md5 to match checksums made by other tools.
+var hashAlgos = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"xxh64":  func() hash.Hash { return xxhash.New() },
+	"blake3": func() hash.Hash { return blake3.New() },
+	"md5":    md5.New,
+}
+
+// newHash returns a hash of the algorithm chosen with --hash-algo.
+func newHash() hash.Hash {
+	return hashAlgos[cfg.HashAlgo]()
+}
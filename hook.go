@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runHook runs a --post-hook or --run-hook command line through the shell.
+// {name} placeholders are replaced by the quoted values of vars, which are also passed
+// as EXISORT_NAME environment variables for scripts that prefer those.
+// Hooks only report problems: a failing hook doesn't undo or fail the import.
+func runHook(command string, vars map[string]string) {
+	if command == "" {
+		return
+	}
+	env := os.Environ()
+	pairs := make([]string, 0, 2*len(vars))
+	for name, value := range vars {
+		pairs = append(pairs, "{"+name+"}", hookArg(name, value))
+		env = append(env, "EXISORT_"+strings.ToUpper(name)+"="+value)
+	}
+	line := strings.NewReplacer(pairs...).Replace(command)
+
+	if cfg.DryRun {
+		log.Info("Would run: %s", line)
+		return
+	}
+	cmd := shellCommand(line)
+	cmd.Env = env
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Warn("Hook %q failed: %v %s", line, err, strings.TrimSpace(string(out)))
+		return
+	}
+	if len(out) > 0 {
+		log.Info("Hook: %s", strings.TrimSpace(string(out)))
+	}
+}
+
+// runSummaryVars are the counters passed to --run-hook.
+func runSummaryVars(src, dst string) map[string]string {
+	return map[string]string{
+		"src":        src,
+		"dest":       dst,
+		"imported":   strconv.FormatInt(stats.FilesProcessed.Load(), 10),
+		"duplicates": strconv.FormatInt(stats.Duplicates.Load(), 10),
+		"errors":     strconv.FormatInt(stats.Errors.Load(), 10),
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+func shellCommand(line string) *exec.Cmd {
+	return exec.Command("/bin/sh", "-c", line)
+}
+
+// hookArg is what the placeholder of a hook variable becomes: the value in single quotes,
+// so paths with spaces or $ reach the command unchanged.
+func hookArg(name, value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
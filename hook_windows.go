@@ -0,0 +1,26 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+func shellCommand(line string) *exec.Cmd {
+	cmd := exec.Command("cmd.exe")
+	// cmd.exe has its own quoting rules, so the line is passed as is instead of being escaped by Go
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd.exe /S /C "` + line + `"`}
+	return cmd
+}
+
+// hookArg is what the placeholder of a hook variable becomes: a reference to its EXISORT_ environment
+// variable in double quotes. cmd.exe expands it once and doesn't look for variables in the result, so a path
+// containing % reaches the command unchanged, which it wouldn't if written into the line.
+// A trailing backslash ("E:\") would escape the closing quote, so it is doubled.
+func hookArg(name, value string) string {
+	arg := "%EXISORT_" + strings.ToUpper(name) + "%"
+	if strings.HasSuffix(value, `\`) {
+		arg += `\`
+	}
+	return `"` + arg + `"`
+}
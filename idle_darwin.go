@@ -0,0 +1,14 @@
+package main
+
+import "golang.org/x/sys/unix"
+
+const (
+	prioDarwinProcess = 4      // PRIO_DARWIN_PROCESS
+	prioDarwinBG      = 0x1000 // PRIO_DARWIN_BG
+)
+
+// lowerPriority moves the process into the background band,
+// which lowers its CPU priority and throttles its disk and network I/O.
+func lowerPriority() error {
+	return unix.Setpriority(prioDarwinProcess, 0, prioDarwinBG)
+}
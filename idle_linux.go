@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioIdle       = 3 << 13 // IOPRIO_CLASS_IDLE
+)
+
+// lowerPriority renices the process to 19 and puts it into the idle I/O class.
+// On Linux both are per thread, so every existing thread is changed;
+// threads started later inherit the values.
+func lowerPriority() error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, 19); err != nil {
+			return err
+		}
+		if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioIdle); errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// lowerPriority enters background processing mode: low CPU, I/O and memory priority.
+func lowerPriority() error {
+	return windows.SetPriorityClass(windows.CurrentProcess(), windows.PROCESS_MODE_BACKGROUND_BEGIN)
+}
@@ -1,189 +1,703 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash"
 	"hash/crc64"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/levmv/exisort/exifdate"
 )
 
+// sourceFile is a file found by the walker, waiting to be scanned.
+type sourceFile struct {
+	Path string
+	Info fs.FileInfo
+	Rel  string // Slash-separated path below the source, as --exclude sees it
+
+	// Set for entries of an archive source; Path is then "<archive>/<Name>" and only used for display
+	FS   fs.FS
+	Name string
+}
+
+// sourceName is the name of the source folder or archive, for {srcdir}.
+var sourceName string
+
+// Run imports srcRoot (a directory or archive, or the --files-from list) into dstRoot in three stages: a single walker lists the files,
+// cfg.Jobs scanners read their dates and fingerprints, and cfg.Jobs importers copy them.
+// With -j 1 this is the original two-goroutine pipeline.
 func Run(ctx context.Context, metaSvc *MetadataService, srcRoot, dstRoot string) error {
+	resetCaches()
+	exifWriter = metaSvc
+	sourceName = ""
+	if cfg.FilesFrom == "" {
+		sourceName = strings.TrimSuffix(filepath.Base(absPath(srcRoot)), archiveExt(srcRoot))
+	}
+
+	var srcFS fs.FS
+	if cfg.FilesFrom == "" && isArchive(srcRoot) {
+		fsys, closer, err := openArchive(srcRoot)
+		if err != nil {
+			return err
+		}
+		defer closer.Close()
+		srcFS = fsys
+	}
+
+	checkingSpace := spaceChecked(srcRoot, dstRoot)
+	preflight := checkingSpace && cfg.Preflight
+	var listed []sourceFile
+	if preflight {
+		listed = listAll(ctx, srcFS, srcRoot, dstRoot)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		checkSpace(listed, dstRoot)
+	}
+	// Running out of space stops the import like Ctrl-C does, but reports why
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	files := make(chan sourceFile, 100)
 	jobs := make(chan FileJob, 100)
 
 	go func() {
-		defer close(jobs)
-		scanSource(ctx, metaSvc, srcRoot, jobs)
+		defer close(files)
+		if !preflight {
+			listSource(ctx, srcFS, srcRoot, dstRoot, files)
+			return
+		}
+		for _, sf := range listed {
+			select {
+			case <-ctx.Done():
+				return
+			case files <- sf:
+			}
+		}
 	}()
 
-	c := 0
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case job, ok := <-jobs:
-			if !ok {
-				return nil
+	var scanners sync.WaitGroup
+	for range cfg.Jobs {
+		scanners.Go(func() {
+			for sf := range files {
+				if ctx.Err() != nil {
+					continue // drain
+				}
+				job, ok := scanFile(metaSvc, sf)
+				if !ok {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+				case jobs <- job:
+				}
 			}
+		})
+	}
+	go func() {
+		scanners.Wait()
+		close(jobs)
+	}()
 
-			destPath := filepath.Join(dstRoot, formatPath(cfg.Format, job.Date, job.Path))
-			c++
-			if c%20 == 0 {
-				log.Status("Scanned: %d | Processing: %s...", stats.FilesScanned.Load(), job.Path)
+	var queue <-chan FileJob = jobs
+	if cfg.PreferOrig {
+		queue = preferOriginals(queue) // Holds back all jobs
+	}
+	if cfg.VideoZoneAuto {
+		queue = inferVideoZones(queue) // Holds back all jobs
+	}
+	switch {
+	case cfg.Bursts != "":
+		queue = groupBursts(queue, dstRoot) // Holds back all jobs as well
+	case organizesInPlace(srcRoot, dstRoot):
+		queue = planFirst(queue)
+	}
+
+	var locks dirLocks
+	var c atomic.Int64
+	var importers sync.WaitGroup
+	for range cfg.Jobs {
+		importers.Go(func() {
+			for job := range queue {
+				if ctx.Err() != nil {
+					continue // drain
+				}
+				if job.Existing != "" {
+					unlock := locks.lock(filepath.Dir(job.Existing))
+					handleDuplicate(job, job.Existing)
+					unlock()
+					continue
+				}
+				if cfg.Conflict == "content-hash" {
+					sum, err := contentSum(job)
+					if err != nil {
+						stats.IncError()
+						receipt.Add("error", job, "", err)
+						log.Error("Failed to hash %s: %v", job.Path, err)
+						continue
+					}
+					job.Sum = sum
+				}
+
+				var destPath string
+				if usesCounter() {
+					destPath = numberJob(dstRoot, &job)
+				} else {
+					destPath = destFor(dstRoot, job)
+				}
+				if checkingSpace && (!haveSpace(dstRoot, job.size()) || !haveSpace(cfg.Dest2, job.size())) {
+					cancel(errDiskFull)
+					continue
+				}
+				if c.Add(1)%20 == 0 {
+					log.Status("Scanned: %d | Processing: %s...", stats.FilesScanned.Load(), job.Path)
+				}
+
+				if cfg.Dest2 != "" {
+					mirrorPath := destFor(cfg.Dest2, job)
+					unlock := locks.lock(filepath.Dir(mirrorPath))
+					mirrored := mirrorOne(job, mirrorPath)
+					unlock()
+					if !mirrored && cfg.Move {
+						log.Warn("Keeping %s in place until it is in the mirror", job.Path)
+						continue
+					}
+				}
+
+				unlock := locks.lock(filepath.Dir(destPath))
+				importOne(ctx, job, destPath)
+				unlock()
+
+				if cfg.Idle {
+					// Give the disk and CPU back to other programs between files
+					time.Sleep(idlePause)
+				}
 			}
+		})
+	}
+	importers.Wait()
 
-			importOne(ctx, job, destPath)
-		}
+	return context.Cause(ctx)
+}
+
+// listSource sends the files selected from the source to the scanners.
+func listSource(ctx context.Context, srcFS fs.FS, srcRoot, dstRoot string, files chan<- sourceFile) {
+	switch {
+	case cfg.FilesFrom != "":
+		readFileList(ctx, cfg.FilesFrom, files)
+	case srcFS != nil:
+		walkArchive(ctx, srcRoot, srcFS, files)
+	default:
+		walkSource(ctx, srcRoot, []string{dstRoot, cfg.Dest2}, files)
 	}
 }
 
-func scanSource(ctx context.Context, metaSvc *MetadataService, root string, jobs chan<- FileJob) {
-	// Decision: We use synchronous filepath.WalkDir instead of a parallel worker pool.
-	// It much simpler. And often not that slower especially on slow disks.
-	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			log.Warn("Skipping path %s: %v", path, err)
-			return nil
+// dirLocks serializes work per destination directory, so two workers never pick the same free name.
+// Files going to different directories are still copied in parallel.
+type dirLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func (d *dirLocks) lock(dir string) (unlock func()) {
+	d.mu.Lock()
+	if d.locks == nil {
+		d.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := d.locks[dir]
+	if !ok {
+		l = &sync.Mutex{}
+		d.locks[dir] = l
+	}
+	d.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// walkSource lists the files to import.
+// Decision: We use synchronous filepath.WalkDir; only the per-file work is spread over workers.
+// Walking is cheap, and parallel walks are often slower on slow disks.
+func walkSource(ctx context.Context, root string, dests []string, files chan<- sourceFile) {
+	feed := feeder{ctx: ctx, files: files}
+	stop := false
+	visited := make(map[string]bool) // Directories entered so far through symlinks, by real path
+	if real, err := realPath(root); err == nil {
+		visited[real] = true
+	}
+	// A library kept inside the source holds only files imported already
+	var libraries []fs.FileInfo
+	for _, dest := range dests {
+		if info, err := os.Stat(dest); dest != "" && err == nil {
+			libraries = append(libraries, info)
 		}
+	}
+	isLibrary := func(dir fs.FileInfo) bool {
+		for _, lib := range libraries {
+			if os.SameFile(dir, lib) {
+				return true
+			}
+		}
+		return false
+	}
+
+	// walk lists dir, which appears as relDir relative to the source.
+	// The trailing separator makes WalkDir follow dir itself if it is a symlink.
+	var walk func(dir, relDir string)
+	walk = func(dir, relDir string) {
+		filepath.WalkDir(dir+string(filepath.Separator), func(path string, d fs.DirEntry, err error) error {
+			if stop {
+				return filepath.SkipAll
+			}
+			if err != nil {
+				log.Warn("Skipping path %s: %v", path, err)
+				return nil
+			}
+
+			rel, _ := filepath.Rel(dir, path)
+			rel = filepath.ToSlash(filepath.Join(relDir, rel))
+			if rel == "." {
+				return nil
+			}
+			if excluded(rel) {
+				log.Info("Skipping %s: excluded", path)
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
 
-		if d.IsDir() {
+			if d.Type()&fs.ModeSymlink != 0 {
+				if !cfg.FollowSymlinks {
+					log.Info("Skipping symlink %s", path)
+					return nil
+				}
+				info, err := os.Stat(path)
+				if err != nil {
+					log.Warn("Skipping broken link %s: %v", path, err)
+					return nil
+				}
+				if info.IsDir() {
+					if isLibrary(info) {
+						log.Info("Skipping %s: destination", path)
+						return nil
+					}
+					real, err := realPath(path)
+					if err != nil || visited[real] {
+						log.Info("Skipping %s: directory already visited", path)
+						return nil
+					}
+					if cfg.MaxDepth > 0 && strings.Count(rel, "/")+1 >= cfg.MaxDepth {
+						return nil
+					}
+					visited[real] = true
+					walk(path, rel)
+					return nil
+				}
+				// Work on the target, so --move moves the file rather than the link
+				real, err := realPath(path)
+				if err != nil {
+					log.Warn("Skipping broken link %s: %v", path, err)
+					return nil
+				}
+				path, d = real, fs.FileInfoToDirEntry(info)
+			}
+
+			if d.IsDir() {
+				if info, err := d.Info(); err == nil && isLibrary(info) {
+					log.Info("Skipping %s: destination", path)
+					return filepath.SkipDir
+				}
+				if cfg.MaxDepth > 0 && strings.Count(rel, "/")+1 >= cfg.MaxDepth {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !wantName(path, rel) {
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				log.Warn("Skipping file info for %s: %v", path, err)
+				return nil
+			}
+
+			if wantFile(path, info) && !feed.send(sourceFile{Path: path, Info: info, Rel: rel}) {
+				stop = true
+				return filepath.SkipAll
+			}
 			return nil
+		})
+	}
+	walk(root, ".")
+}
+
+// wantName applies the filters that only need the name: --extensions (unless --sniff) and --include.
+func wantName(path, rel string) bool {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if !cfg.Sniff && !cfg.Extensions[ext] {
+		return false
+	}
+	if cfg.Sidecars && isSidecar(path) {
+		return false // Travels with its photo
+	}
+	return len(cfg.Include) == 0 || matchAny(cfg.Include, rel)
+}
+
+// wantFile applies the filters that need the file info: --min-size, --since-last-run and the journal.
+func wantFile(path string, info fs.FileInfo) bool {
+	if info.Size() < cfg.MinSizeBytes {
+		if cfg.Verbose {
+			log.Warn("Skipping %s: too small (%d B)", path, info.Size())
 		}
+		return false
+	}
 
-		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
-		if !cfg.Extensions[ext] {
-			return nil
+	if !cfg.Since.IsZero() && changeTime(info).Before(cfg.Since) {
+		log.Info("Skipping %s: unchanged since the last run", path)
+		return false
+	}
+
+	if journal.Done(path, info) {
+		log.Info("Skipping %s: already imported", path)
+		return false
+	}
+	return true
+}
+
+// wantContent is the --sniff filter: the file must look like a photo or video, whatever its name.
+func wantContent(path string, head []byte) bool {
+	if !cfg.Sniff {
+		return true
+	}
+	if exifdate.Detect(head) == "" {
+		log.Info("Skipping %s: not a known photo or video format", path)
+		return false
+	}
+	return true
+}
+
+// wantDate is the --after/--before filter. It compares wall times, the same ones the destination path is made of.
+func wantDate(path string, t time.Time) bool {
+	if cfg.After.IsZero() && cfg.Before.IsZero() {
+		return true
+	}
+	if cfg.Zone == "local" {
+		t = t.In(time.Local)
+	}
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	if wall.Before(cfg.After) || (!cfg.Before.IsZero() && !wall.Before(cfg.Before)) {
+		log.Info("Skipping %s: taken %s, out of the date range", path, t.Format(time.DateTime))
+		return false
+	}
+	return true
+}
+
+// feeder hands selected files to the scanners.
+type feeder struct {
+	ctx   context.Context
+	files chan<- sourceFile
+	sent  int
+}
+
+// send queues sf. It returns false once no more files should be sent,
+// because the run was cancelled or --limit is reached.
+func (f *feeder) send(sf sourceFile) bool {
+	select {
+	case <-f.ctx.Done():
+		return false
+	case f.files <- sf:
+	}
+
+	if f.sent++; cfg.Limit > 0 && f.sent >= cfg.Limit {
+		log.Info("Stopping after %d files (--limit)", f.sent)
+		return false
+	}
+	return true
+}
+
+// realPath resolves all symlinks in path and makes it absolute.
+func realPath(path string) (string, error) {
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Abs(real)
+}
+
+// scanFile reads the date and fingerprint of a file.
+func scanFile(metaSvc *MetadataService, sf sourceFile) (FileJob, bool) {
+	if sf.FS != nil {
+		return scanEntry(sf)
+	}
+	path, info := sf.Path, sf.Info
+
+	if cfg.LivePhotos {
+		if photo := findLivePhoto(metaSvc, path, sf.Rel); photo != "" {
+			log.Info("Skipping %s: Live Photo video of %s", path, photo)
+			return FileJob{}, false
 		}
+	}
 
-		info, err := d.Info()
-		if err != nil {
-			log.Warn("Skipping file info for %s: %v", path, err)
-			return nil
+	f, err := os.Open(path)
+	if err != nil {
+		log.Warn("Skipping file info for %s: %v", path, err)
+		return FileJob{}, false
+	}
+	defer f.Close()
+
+	// We read up to 64KB to generate a "Short Hash" and validify file type.
+	head := make([]byte, 64*1024)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		log.Warn("Failed to read header %s: %v", path, err)
+		return FileJob{}, false
+	}
+	validHead := head[:n]
+	if !wantContent(path, validHead) {
+		return FileJob{}, false
+	}
+
+	var ext string
+	if cfg.FixExt {
+		if ext = fixedExt(path, validHead); ext != "" {
+			log.Info("Wrong extension: %s is .%s", path, ext)
 		}
+	}
 
-		if info.Size() < cfg.MinSizeBytes {
-			if cfg.Verbose {
-				log.Warn("Skipping %s: too small (%d B)", path, info.Size())
+	// Motion photos carry a short video after the image data. A stripped one is
+	// fingerprinted without it, so this is needed before looking it up in the index.
+	var motionOffset int64
+	findMotion := func() {
+		if motionOffset, _ = exifdate.MotionPhotoOffset(f); motionOffset > 0 {
+			log.Info("Motion photo: %s", path)
+			if cfg.MotionPhoto == "strip" && int64(len(validHead)) > motionOffset {
+				validHead = validHead[:motionOffset]
 			}
-			return nil
 		}
+	}
+	if cfg.MotionPhoto == "strip" {
+		findMotion()
+	}
 
-		f, err := os.Open(path)
-		if err != nil {
-			log.Warn("Skipping file info for %s: %v", path, err)
-			return nil
-		}
-		defer f.Close()
+	hash := computeFingerprint(validHead, info.Size())
 
-		// We read up to 64KB to generate a "Short Hash" and validify file type.
-		head := make([]byte, 64*1024)
-		n, err := io.ReadFull(f, head)
-		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-			log.Warn("Failed to read header %s: %v", path, err)
-			return nil
+	// A file already in the library is recognized by the index alone, without reading its metadata.
+	// Not if the date decides whether it is imported at all, or is needed for the mirror.
+	if destIndex != nil && cfg.After.IsZero() && cfg.Before.IsZero() && filterHook == nil && cfg.Dest2 == "" {
+		job := FileJob{Path: path, Rel: sf.Rel, Info: info, SourceHead: validHead, Hash: hash, MotionOffset: motionOffset}
+		if job.Existing = destIndex.Find(job); job.Existing != "" {
+			if cfg.LivePhotos {
+				job.LiveVideo = findLiveVideo(path)
+			}
+			stats.IncScanned()
+			return job, true
 		}
-		validHead := head[:n]
+	}
 
-		f.Seek(0, 0)
+	// Extract Date (EXIF or Fallback)
+	f.Seek(0, 0)
+	d := metaSvc.Resolve(f, info)
+	logDate(path, d)
+	if !wantDate(path, d.Time) || !wantScreenshot(path, d.Meta) || !filterHook.Want(path, d, info.Size()) {
+		return FileJob{}, false
+	}
 
-		// Extract Date (EXIF or Fallback)
-		date := metaSvc.GetTime(f, info)
+	if cfg.MotionPhoto != "strip" {
+		findMotion()
+	}
 
-		hash := computeFingerprint(validHead, info.Size())
+	var sidecars []string
+	if cfg.Sidecars {
+		sidecars = findSidecars(path)
+	}
+	var liveVideo string
+	if cfg.LivePhotos {
+		liveVideo = findLiveVideo(path)
+	}
 
-		stats.IncScanned()
+	stats.IncScanned()
 
-		select {
-		case <-ctx.Done():
-			return filepath.SkipAll
-		case jobs <- FileJob{
-			Path:       path,
-			Info:       info,
-			Date:       date,
-			SourceHead: validHead,
-			Hash:       hash,
-		}:
-		}
+	job := FileJob{
+		Path:       path,
+		Rel:        sf.Rel,
+		Info:       info,
+		Date:       d.Time,
+		DateSource: d.Source,
+		Meta:       d.Meta,
+		SourceHead: validHead,
+		Hash:       hash,
 
-		return nil
-	})
+		MotionOffset: motionOffset,
+		Ext:          ext,
+		Convert:      convertExt(path, ext),
+		Screenshot:   cfg.Screenshots == "separate" && isScreenshot(path, d.Meta),
+		Sidecars:     sidecars,
+		LiveVideo:    liveVideo,
+	}
+	geotag(&job)
+	return job, true
 }
 
+// idlePause is the break taken after every file with --idle.
+const idlePause = 20 * time.Millisecond
+
 func importOne(ctx context.Context, job FileJob, originalDest string) {
-	finalDest := originalDest
+	// 0. Same content already in the library, possibly under another name
+	if existing := destIndex.Find(job); existing != "" {
+		handleDuplicate(job, existing)
+		return
+	}
 
 	// 1. Resolve Conflicts & Detect Duplicates
-	if _, err := os.Stat(finalDest); err == nil {
-
-		// Case A: Exact Match at Target (No Rename needed)
-		if isFileIdentical(job, finalDest) {
-			handleDuplicate(job)
-			return
+	finalDest, duplicate := resolveConflict(job, originalDest)
+	switch {
+	case duplicate != "":
+		handleDuplicate(job, duplicate)
+	case finalDest == "":
+		plan.Add("skip", job.Path, originalDest)
+		if !cfg.DryRun {
+			journal.Record(job, "skipped", originalDest)
+			receipt.Add("skipped", job, originalDest, nil)
 		}
+	default:
+		// 2. Perform Copy/Move to the resolved finalDest
+		transferFile(job, finalDest)
+	}
+}
 
-		// Conflict handling based on config
-		if cfg.Conflict == "skip" {
-			return
-		} else if cfg.Conflict == "overwrite" {
-			// Do nothing, let it fall through to copy logic
-		} else {
-			// Mode: "rename" (Default)
-
-			// Case B: Try appending Short Hash
-			// "Image.jpg" -> "Image_A1B2C3D4.jpg"
-			ext := filepath.Ext(originalDest)
-			base := strings.TrimSuffix(originalDest, ext)
-
-			// TODO: 16-char hex hash probably is too much. Maybe just got half of it?
-			hashedDest := fmt.Sprintf("%s_%08x%s", base, job.Hash, ext)
-
-			if _, err := os.Stat(hashedDest); os.IsNotExist(err) {
-				// Slot is free!
-				finalDest = hashedDest
-			} else {
-				// File with Hash exists. Is it the same file?
-				// (e.g. we ran import twice and previous run renamed it)
-				if isFileIdentical(job, hashedDest) {
-					handleDuplicate(job)
-					return
-				}
+// resolveConflict picks the path for job if originalDest is taken, following --conflict.
+// It returns either the path to write to or an existing file with the same content;
+// both are empty if the file is to be skipped.
+func resolveConflict(job FileJob, originalDest string) (dest, duplicate string) {
+	if !destExists(originalDest) {
+		return originalDest, ""
+	}
 
-				// Case C: Hash Collision (Rare) or file content changed.
-				// Start counting: "Image_A1B2C3D4_1.jpg"
-				n := 1
-				for {
-					counterDest := fmt.Sprintf("%s_%08x_%d%s", base, job.Hash, n, ext)
-					if _, err := os.Stat(counterDest); os.IsNotExist(err) {
-						finalDest = counterDest
-						break
-					}
-					if isFileIdentical(job, counterDest) {
-						handleDuplicate(job)
-						return
-					}
-					n++
-				}
+	// Case A: Exact Match at Target (No Rename needed)
+	if isFileIdentical(job, originalDest) {
+		return "", originalDest
+	}
+
+	// Conflict handling based on config
+	ext := filepath.Ext(originalDest)
+	base := strings.TrimSuffix(originalDest, ext)
+	switch cfg.Conflict {
+	case "skip":
+		return "", ""
+	case "overwrite":
+		return originalDest, ""
+	case "overwrite-if-older", "keep-larger":
+		existing, err := os.Stat(originalDest)
+		if err != nil {
+			return "", ""
+		}
+		if cfg.Conflict == "overwrite-if-older" && job.Info.ModTime().After(existing.ModTime()) {
+			log.Info("Replacing %s: %s is newer", originalDest, job.Path)
+			return originalDest, ""
+		}
+		if cfg.Conflict == "keep-larger" && job.size() > existing.Size() {
+			log.Info("Replacing %s: %s is larger", originalDest, job.Path)
+			return originalDest, ""
+		}
+		return "", ""
+	case "counter":
+		// "Image.jpg" -> "Image_1.jpg", "Image_2.jpg"...
+		for n := 1; ; n++ {
+			counterDest := fmt.Sprintf("%s_%d%s", base, n, ext)
+			if !destExists(counterDest) {
+				return counterDest, ""
+			}
+			if isFileIdentical(job, counterDest) {
+				return "", counterDest
 			}
 		}
 	}
 
-	// 2. Perform Copy/Move to the resolved finalDest
-	transferFile(job, finalDest)
+	// Mode: "rename" (Default)
+
+	// Case B: Try appending Short Hash
+	// "Image.jpg" -> "Image_A1B2C3D4.jpg"
+
+	hashedDest := fmt.Sprintf("%s_%s%s", base, hashSuffix(job.Hash), ext)
+
+	if !destExists(hashedDest) {
+		// Slot is free!
+		return hashedDest, ""
+	}
+	// File with Hash exists. Is it the same file?
+	// (e.g. we ran import twice and previous run renamed it)
+	if isFileIdentical(job, hashedDest) {
+		return "", hashedDest
+	}
+
+	// Case C: Hash Collision (Rare) or file content changed.
+	// Start counting: "Image_A1B2C3D4_1.jpg"
+	for n := 1; ; n++ {
+		counterDest := fmt.Sprintf("%s_%s_%d%s", base, hashSuffix(job.Hash), n, ext)
+		if !destExists(counterDest) {
+			return counterDest, ""
+		}
+		if isFileIdentical(job, counterDest) {
+			return "", counterDest
+		}
+	}
+}
+
+// hashSuffix formats the fingerprint appended to names with --conflict rename.
+// By default it is the whole fingerprint in hex, as before there were options;
+// --suffix-length keeps only its last digits.
+func hashSuffix(hash uint64) string {
+	var digits string
+	switch cfg.SuffixEncoding {
+	case "base36":
+		digits = strconv.FormatUint(hash, 36)
+		digits = strings.Repeat("0", 13-len(digits)) + digits
+	default:
+		if cfg.SuffixLength == 0 {
+			return fmt.Sprintf("%08x", hash)
+		}
+		digits = fmt.Sprintf("%016x", hash)
+	}
+	if cfg.SuffixLength > 0 && cfg.SuffixLength < len(digits) {
+		digits = digits[len(digits)-cfg.SuffixLength:]
+	}
+	return digits
+}
+
+// overwrites reports whether --conflict may replace files in the library.
+func overwrites() bool {
+	switch cfg.Conflict {
+	case "overwrite", "overwrite-if-older", "keep-larger":
+		return true
+	}
+	return false
 }
 
 func isFileIdentical(job FileJob, existingPath string) bool {
+	if other, ok := plan.claimant(existingPath); ok {
+		return other.Hash == job.Hash && other.size() == job.size()
+	}
 	info, err := os.Stat(existingPath)
 	if err != nil {
 		return false
 	}
 
-	if info.Size() != job.Info.Size() {
+	if info.Size() != job.size() {
 		return false
 	}
 
@@ -192,19 +706,26 @@ func isFileIdentical(job FileJob, existingPath string) bool {
 	}
 
 	if cfg.DeepCheck || cfg.Move {
-		fullMatch, _ := areFilesDeepIdentical(job.Path, existingPath)
+		if job.FS != nil {
+			return isEntryIdentical(job, existingPath)
+		}
+		fullMatch, _ := areFilesDeepIdentical(job.Path, existingPath, job.size())
 		return fullMatch
 	}
 
 	return true
 }
 
-func handleDuplicate(job FileJob) {
+func handleDuplicate(job FileJob, existingPath string) {
+	if isSelf(job, existingPath) {
+		log.Info("%s is already in place", job.Path)
+		return
+	}
 	stats.IncDuplicate()
 
 	if cfg.DryRun {
 		log.Duplicate(job.Path)
-		// log.Action(tag.Dry(), "%s (Duplicate)", job.Path)
+		plan.Add("duplicate", job.Path, existingPath)
 		return
 	}
 
@@ -215,39 +736,126 @@ func handleDuplicate(job FileJob) {
 		}
 	}
 	log.Duplicate(job.Path)
+	journal.Record(job, "duplicate", existingPath)
+	receipt.Add("duplicate", job, existingPath, nil)
+	cleanupTakeout(job)
+	if job.LiveVideo != "" {
+		transferLiveVideo(job, existingPath)
+	}
 }
 
 func transferFile(job FileJob, destPath string) {
 	if cfg.DryRun {
-		log.Transfer(job.Path, destPath)
+		plan.claim(job, destPath)
+		dryTransfer(job.Path, destPath, job.size())
+		transferSidecars(job, destPath)
+		if job.LiveVideo != "" {
+			transferLiveVideo(job, destPath)
+		}
 		return
 	}
 
 	if err := os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
 		stats.IncError()
+		receipt.Add("error", job, destPath, err)
 		log.Error("Mkdir failed for %s: %v", destPath, err)
 		return
 	}
 
 	var err error
-	if cfg.Move {
+	switch {
+	case job.Convert != "":
+		if err = convertJob(job, destPath); err == nil && cfg.Move && job.FS == nil {
+			os.Remove(job.Path)
+		}
+	case job.FS != nil:
+		err = copyEntry(job, destPath)
+	case cfg.Symlink:
+		var target string
+		if target, err = filepath.Abs(job.Path); err == nil {
+			if overwrites() {
+				os.Remove(destPath)
+			}
+			err = os.Symlink(target, destPath)
+		}
+	case job.size() < job.Info.Size():
+		// Stripped motion photo: only the still image is written
+		if err = copyPart(job.Path, destPath, 0, job.size(), job.Info); err == nil && cfg.Move {
+			os.Remove(job.Path)
+		}
+	case cfg.Move:
 		if err = os.Rename(job.Path, destPath); err != nil {
-			if err = copyFile(job.Path, destPath, job.Info); err == nil {
+			if err = copyFile(job.Path, destPath, job.Info, job.SourceHead); err == nil {
 				os.Remove(job.Path)
 			}
 		}
-	} else {
-		err = copyFile(job.Path, destPath, job.Info)
+	default:
+		err = copyFile(job.Path, destPath, job.Info, job.SourceHead)
 	}
 
-	if err != nil {
+	switch {
+	case errors.Is(err, errAborted):
+		log.Warn("Aborted %s before it was complete", job.Path)
+		return
+	case err != nil:
 		stats.IncError()
+		receipt.Add("error", job, destPath, err)
 		log.Error("IO Error %s: %v", job.Path, err)
-	} else {
-		stats.IncProcessed()
-		stats.AddBytes(job.Info.Size())
-		log.Transfer(job.Path, destPath)
+		return
+	}
+	noteWritten(destPath)
+	stats.IncProcessed()
+	log.Transfer(job.Path, destPath)
+	switch {
+	case cfg.Symlink:
+		journal.Record(job, "linked", destPath)
+		receipt.Add("linked", job, destPath, nil)
+	case cfg.Move:
+		stats.AddBytes(job.size())
+		journal.Record(job, "moved", destPath)
+		receipt.Add("moved", job, destPath, nil)
+	default:
+		stats.AddBytes(job.size())
+		journal.Record(job, "copied", destPath)
+		receipt.Add("copied", job, destPath, nil)
+	}
+	destIndex.Add(job, destPath)
+	cleanupTakeout(job)
+	transferSidecars(job, destPath)
+	if job.LiveVideo != "" {
+		transferLiveVideo(job, destPath)
+	}
+
+	if cfg.MotionPhoto == "extract" && job.MotionOffset > 0 && job.Convert == "" {
+		extractMotionVideo(job, destPath, destPath)
+	}
+	sum, size := job.Sum, job.size()
+	if edited := editCopy(job, destPath); edited || job.Convert != "" {
+		// The copy no longer matches the source
+		sum = ""
+		if info, err := os.Stat(destPath); err == nil {
+			size = info.Size()
+		}
+	}
+	recordSum(destPath, sum, size)
+	runHook(cfg.PostHook, map[string]string{"src": job.Path, "dest": destPath})
+}
+
+// extractMotionVideo saves the video of a motion photo next to its destination, e.g. "IMG_0001.mp4".
+// It reads from src, usually the imported copy, since in move mode the source is already gone.
+func extractMotionVideo(job FileJob, src, destPath string) {
+	videoPath := strings.TrimSuffix(destPath, filepath.Ext(destPath)) + ".mp4"
+	if _, err := os.Stat(videoPath); err == nil {
+		return
+	}
+
+	if err := copyPart(src, videoPath, job.MotionOffset, job.Info.Size()-job.MotionOffset, job.Info); err != nil {
+		stats.IncError()
+		log.Error("Failed to extract video from %s: %v", destPath, err)
+		return
 	}
+	noteWritten(videoPath)
+	log.Info("Extracted video %s", videoPath)
 }
 
 // areHeadersIdentical compares the in-memory source header against the destination file on disk.
@@ -264,15 +872,18 @@ func areHeadersIdentical(destPath string, sourceHead []byte) bool {
 	return n == len(sourceHead) && string(destHead) == string(sourceHead)
 }
 
-func areFilesDeepIdentical(src, dst string) (bool, error) {
-	h1, err := computeFullHash(src)
+func areFilesDeepIdentical(src, dst string, size int64) (bool, error) {
+	h1, err := computeFullHash(src, size)
 	if err != nil {
 		return false, err
 	}
 
-	h2, err := computeFullHash(dst)
-	if err != nil {
-		return false, err
+	// A checksum stored with --checksums saves reading the file in the library
+	h2 := storedSum(dst)
+	if h2 == "" {
+		if h2, err = computeFullHash(dst, size); err != nil {
+			return false, err
+		}
 	}
 
 	return h1 == h2, nil
@@ -292,65 +903,284 @@ func computeFingerprint(header []byte, size int64) uint64 {
 	return h.Sum64()
 }
 
-// computeFullHash calculates the SHA256 of the entire file.
+// computeFullHash calculates the SHA256 of the first size bytes of the file (normally all of it).
 // Used for the --deep check to ensure absolute duplicate safety.
-func computeFullHash(path string) (string, error) {
+func computeFullHash(path string, size int64) (string, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
+	adviseSequential(f)
+
+	return hashReader(f, size)
+}
 
-	h := sha256.New()
+// hashReader is computeFullHash for an open reader.
+func hashReader(r io.Reader, size int64) (string, error) {
+	h := newHash()
 
-	if _, err := io.Copy(h, f); err != nil {
+	if n, err := copyBuffer(h, io.LimitReader(r, size)); err != nil {
 		return "", err
+	} else if n < size {
+		return "", io.EOF
 	}
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
-func formatPath(fmtStr string, t time.Time, path string) string {
-	_, file := filepath.Split(path)
-	ext := filepath.Ext(file)
-	name := strings.TrimSuffix(file, ext)
-	if len(ext) > 0 {
-		ext = ext[1:] // remove dot
+// contentSum returns the full hash of the data job puts into the library.
+func contentSum(job FileJob) (string, error) {
+	if job.FS == nil {
+		return computeFullHash(job.Path, job.size())
 	}
+	f, err := job.FS.Open(job.Name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return hashReader(f, job.size())
+}
 
-	// Use t.Format for everything. It's cleaner.
-	r := strings.NewReplacer(
-		"{year}", t.Format("2006"),
-		"{month}", t.Format("01"),
-		"{day}", t.Format("02"),
-		"{hour}", t.Format("15"),
-		"{min}", t.Format("04"),
-		"{sec}", t.Format("05"),
-		"{filename}", name,
-		"{ext}", ext,
-	)
-	return r.Replace(fmtStr)
+// contentNameLen is how many hex digits of the content hash name a file with --conflict content-hash.
+const contentNameLen = 12
+
+// destFor is where job goes according to --format.
+func destFor(dstRoot string, job FileJob) string {
+	date := job.Date
+	if cfg.Zone == "local" {
+		date = date.In(time.Local)
+	}
+
+	name := job.Path
+	if job.Convert != "" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + job.Convert
+	} else if job.Ext != "" {
+		name = strings.TrimSuffix(name, filepath.Ext(name)) + "." + job.Ext
+	}
+	if p := profileFor(job.Meta); p != nil && p.Dest != "" {
+		dstRoot = filepath.Join(dstRoot, p.Dest)
+	}
+	if cfg.NoDateDir != "" && job.DateSource == "mtime" {
+		// Only the file time to go by: keep it apart for a manual check
+		dstRoot = filepath.Join(dstRoot, cfg.NoDateDir)
+	}
+	destPath := filepath.Join(dstRoot, formatPath(formatFor(name, job), date, name, job))
+	if job.Burst > 0 {
+		destPath = burstPath(destPath, job.Burst)
+	}
+	if job.Sum != "" {
+		// --conflict content-hash: the same content always gets the same name
+		destPath = filepath.Join(filepath.Dir(destPath), job.Sum[:contentNameLen]+filepath.Ext(destPath))
+	}
+	return destPath
+}
+
+// copySource copies the content of job that goes into the library to dst.
+func copySource(job FileJob, dst string) error {
+	switch {
+	case job.FS != nil:
+		return copyEntry(job, dst)
+	case job.size() < job.Info.Size():
+		return copyPart(job.Path, dst, 0, job.size(), job.Info)
+	}
+	return copyFile(job.Path, dst, job.Info, job.SourceHead)
 }
 
-func copyFile(src, dst string, srcInfo fs.FileInfo) error {
+// copyFile copies src to dst. head, if not nil, is the start of src as read when scanning it;
+// it is written from memory rather than read again.
+func copyFile(src, dst string, srcInfo fs.FileInfo, head []byte) error {
+	var err error
+	if tryClone(src, dst) {
+		err = verifyClone(src, dst, srcInfo.Size())
+		os.Remove(dst + partSuffix) // From an earlier copy that was interrupted
+	} else {
+		err = copyContents(src, dst, srcInfo, head)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chtimes(dst, time.Now(), srcInfo.ModTime()); err != nil {
+		// log.Warn("Fail to upgrade file time: %v", err)
+	}
+	preserveAttrs(src, dst, srcInfo)
+
+	return nil
+}
+
+// copyContents writes the data of src into dst, see copyFile.
+func copyContents(src, dst string, srcInfo fs.FileInfo, head []byte) error {
 	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer in.Close()
+	adviseSequential(in)
+
+	// The head is only good if the file hasn't changed since
+	if info, err := in.Stat(); err != nil || info.Size() != srcInfo.Size() || !info.ModTime().Equal(srcInfo.ModTime()) {
+		head = nil
+	}
+	return writeFile(dst, in, head)
+}
 
-	out, err := os.Create(dst)
+// copyPart copies n bytes of src starting at off into dst.
+func copyPart(src, dst string, off, n int64, srcInfo fs.FileInfo) error {
+	in, err := os.Open(src)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	defer in.Close()
+	adviseSequential(in)
 
-	if _, err = io.Copy(out, in); err != nil {
+	if err := writeFile(dst, io.NewSectionReader(in, off, n), nil); err != nil {
 		return err
 	}
 
-	if err := os.Chtimes(dst, time.Now(), srcInfo.ModTime()); err != nil {
-		// log.Warn("Fail to upgrade file time: %v", err)
+	os.Chtimes(dst, time.Now(), srcInfo.ModTime())
+	preserveAttrs(src, dst, srcInfo)
+	return nil
+}
+
+// partSuffix marks a file still being written. Only complete files get their real name,
+// so a copy cut short never looks like an imported file.
+const partSuffix = ".part"
+
+// writeFile writes r to dst by way of dst.part. A part file left by an interrupted copy is kept
+// and resumed next time, unless it turns out to be wrong. If head is given, it holds the first
+// bytes of r, which must then be an io.Seeker.
+func writeFile(dst string, r io.Reader, head []byte) error {
+	part := dst + partSuffix
+	h := newHash()
+	out, done, err := openPart(part, r, h)
+	if err != nil {
+		return err
+	}
+
+	if done == 0 && len(head) > 0 {
+		if _, err = r.(io.Seeker).Seek(int64(len(head)), io.SeekStart); err == nil {
+			_, err = out.Write(head)
+			h.Write(head)
+			done = int64(len(head))
+		}
+	}
+	if err == nil {
+		err = copyData(out, r, h, done)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(part, dst)
+	}
+	if err != nil {
+		if info, serr := os.Stat(part); errors.Is(err, errVerifyFailed) || (serr == nil && info.Size() == 0) {
+			os.Remove(part)
+		}
+	}
+	return err
+}
+
+// openPart opens part for writing. If it already has data and r can seek, that data is compared
+// with the start of r: if they match, r and the returned file are positioned after it, h holds
+// its hash and done its length. Otherwise the part file starts over.
+func openPart(part string, r io.Reader, h hash.Hash) (out *os.File, done int64, err error) {
+	out, err = os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := out.Stat()
+	if err != nil {
+		out.Close()
+		return nil, 0, err
+	}
+	src, ok := r.(io.Seeker)
+	if info.Size() == 0 || !ok {
+		return out, 0, out.Truncate(0)
+	}
+
+	written := newHash()
+	n, err := copyBuffer(written, out)
+	if err == nil {
+		var m int64
+		if m, err = copyBuffer(h, io.LimitReader(r, n)); err == nil && m == n && bytes.Equal(h.Sum(nil), written.Sum(nil)) {
+			log.Info("Resuming %s after %s", part, formatBytes(n))
+			return out, n, nil
+		}
+	}
+	if err != nil {
+		out.Close()
+		return nil, 0, err
+	}
+
+	h.Reset()
+	if _, err = src.Seek(0, io.SeekStart); err == nil {
+		if _, err = out.Seek(0, io.SeekStart); err == nil {
+			err = out.Truncate(0)
+		}
+	}
+	if err != nil {
+		out.Close()
+		return nil, 0, err
+	}
+	return out, 0, nil
+}
+
+var errVerifyFailed = errors.New("verification failed: destination differs from source")
+
+// copyData copies r into out, after the first done bytes already there, whose hash is in h.
+// With --verify, the written file is read back and its checksum compared with the data read from the source.
+func copyData(out *os.File, r io.Reader, h hash.Hash, done int64) error {
+	if !cfg.Verify {
+		_, err := copyBuffer(out, r)
+		return err
 	}
 
+	n, err := copyBuffer(out, io.TeeReader(r, h))
+	if err != nil {
+		return err
+	}
+	n += done
+	if err := out.Sync(); err != nil {
+		return err
+	}
+
+	written, err := computeFullHash(out.Name(), n)
+	if err != nil {
+		return err
+	}
+	if written != fmt.Sprintf("%x", h.Sum(nil)) {
+		return errVerifyFailed
+	}
+	if info, err := out.Stat(); err != nil || info.Size() != n {
+		return errVerifyFailed
+	}
 	return nil
 }
+
+// copyBuffer is io.Copy with the buffer size set by --buffer-size, limited to --bwlimit.
+// Without either, the kernel may copy between files directly (copy_file_range).
+// It copies in chunks, so that a second Ctrl-C can abort it in between.
+func copyBuffer(dst io.Writer, src io.Reader) (int64, error) {
+	if bwLimit != nil {
+		src = throttledReader{src}
+	}
+	var buf []byte
+	if cfg.BufferSize > 0 {
+		// Hide ReadFrom/WriteTo, which would bypass the buffer
+		dst, src = struct{ io.Writer }{dst}, struct{ io.Reader }{src}
+		buf = make([]byte, cfg.BufferSize)
+	}
+
+	var total int64
+	for {
+		if copiesAborted.Load() {
+			return total, errAborted
+		}
+		n, err := io.CopyBuffer(dst, io.LimitReader(src, copyChunk), buf)
+		total += n
+		if err != nil || n < copyChunk {
+			return total, err
+		}
+	}
+}
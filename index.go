@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// DestIndex remembers the fingerprint of every file in the library (<dest>/.exisort/index.tsv),
+// so content that was already imported under another name or into another folder is recognized.
+// Matches are always verified against the file on disk, so a stale index can't cause data loss.
+type DestIndex struct {
+	mu     sync.Mutex
+	root   string
+	f      *os.File
+	w      *csv.Writer
+	byHash map[uint64][]string // Paths relative to root
+}
+
+var destIndex *DestIndex
+
+const indexFile = "index.tsv"
+
+// OpenIndex loads the index of dstRoot, or returns nil if there is none.
+// With rebuild set, the index is (re)built from the files in the library instead.
+func OpenIndex(dstRoot string, rebuild bool) (*DestIndex, error) {
+	path := filepath.Join(dstRoot, metaDir, indexFile)
+	_, err := os.Stat(path)
+	exists := err == nil && !rebuild
+	if !exists && !rebuild {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	flags := os.O_RDWR | os.O_CREATE | os.O_APPEND
+	if rebuild {
+		flags |= os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	x := &DestIndex{root: dstRoot, f: f, w: csv.NewWriter(f), byHash: make(map[uint64][]string)}
+	x.w.Comma = '\t'
+
+	if exists {
+		r := csv.NewReader(f)
+		r.Comma = '\t'
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		for {
+			rec, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil || len(rec) < 3 {
+				continue
+			}
+			if h, err := strconv.ParseUint(rec[0], 16, 64); err == nil {
+				x.byHash[h] = append(x.byHash[h], rec[2])
+			}
+		}
+		return x, nil
+	}
+
+	return x, x.build()
+}
+
+// build fingerprints the files already in the library.
+func (x *DestIndex) build() error {
+	n := 0
+	err := filepath.WalkDir(x.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == metaDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		head, err := readHead(path)
+		if err != nil {
+			log.Warn("Skipping %s: %v", path, err)
+			return nil
+		}
+
+		x.add(computeFingerprint(head, info.Size()), info.Size(), path)
+		n++
+		if n%100 == 0 {
+			log.Status("Indexing library: %d files...", n)
+		}
+		return nil
+	})
+	x.w.Flush()
+	if err == nil {
+		err = x.w.Error()
+	}
+	log.ClearStatus()
+	log.Info("Indexed %d files in %s", n, x.root)
+	return err
+}
+
+// Find returns a file in the library with the same content as job, or "".
+func (x *DestIndex) Find(job FileJob) string {
+	if x == nil {
+		return ""
+	}
+	x.mu.Lock()
+	candidates := x.byHash[job.Hash]
+	x.mu.Unlock()
+
+	for _, rel := range candidates {
+		path := filepath.Join(x.root, rel)
+		if isFileIdentical(job, path) {
+			return path
+		}
+	}
+	return ""
+}
+
+// Add records a file written to the library.
+func (x *DestIndex) Add(job FileJob, destPath string) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.add(job.Hash, job.size(), destPath)
+	x.w.Flush()
+	if err := x.w.Error(); err != nil {
+		log.Warn("Failed to write index: %v", err)
+	}
+}
+
+func (x *DestIndex) add(hash uint64, size int64, path string) {
+	rel, err := filepath.Rel(x.root, path)
+	if err != nil {
+		return
+	}
+	x.byHash[hash] = append(x.byHash[hash], rel)
+	x.w.Write([]string{fmt.Sprintf("%016x", hash), strconv.FormatInt(size, 10), rel})
+}
+
+func (x *DestIndex) Close() error {
+	if x == nil {
+		return nil
+	}
+	return x.f.Close()
+}
+
+// readHead reads the part of a file used for the fingerprint.
+func readHead(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	head := make([]byte, 64*1024)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// organizesInPlace reports whether the source folder lies within the destination, e.g. to tidy up
+// an existing library. Files are then only moved, and only after the whole source has been scanned,
+// so that a file moved into place is never picked up again as a new one.
+func organizesInPlace(srcRoot, dstRoot string) bool {
+	if srcRoot == "" || cfg.FilesFrom != "" || isArchive(srcRoot) {
+		return false
+	}
+	src, err := realPath(srcRoot)
+	if err != nil {
+		return false
+	}
+	dst, err := realPath(dstRoot)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dst, src)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// planFirst holds back all jobs until scanning is complete.
+func planFirst(in <-chan FileJob) <-chan FileJob {
+	out := make(chan FileJob, 100)
+	go func() {
+		defer close(out)
+		var plan []FileJob
+		for job := range in {
+			plan = append(plan, job)
+		}
+		log.Info("Planned %d files, moving them into place", len(plan))
+		for _, job := range plan {
+			out <- job
+		}
+	}()
+	return out
+}
+
+// isSelf reports whether path is the source file of job itself, which happens when organizing in place.
+func isSelf(job FileJob, path string) bool {
+	if job.FS != nil {
+		return false
+	}
+	a, err := os.Stat(job.Path)
+	if err != nil {
+		return false
+	}
+	b, err := os.Stat(path)
+	return err == nil && os.SameFile(a, b)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+)
+
+// copyChunk is how much is copied at a time between checks for an abort.
+const copyChunk = 16 << 20
+
+// errAborted is returned by copies cut short by a second Ctrl-C.
+var errAborted = errors.New("aborted by user")
+
+var copiesAborted atomic.Bool
+
+// handleInterrupts returns a context that is canceled on the first Ctrl-C (or SIGTERM): no new files
+// are started, but those in progress are finished, so the summary and journal stay accurate.
+// A second Ctrl-C aborts the files in progress too, leaving part files to resume (see writeFile).
+// A third one is no longer caught and kills the program, should it hang elsewhere, e.g. in ExifTool.
+func handleInterrupts() (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if _, ok := <-signals; !ok {
+			return
+		}
+		log.ClearStatus()
+		log.Warn("Stopping after the files in progress, press Ctrl-C again to abort them")
+		cancel()
+		if _, ok := <-signals; !ok {
+			return
+		}
+		signal.Stop(signals)
+		log.Warn("Aborting, press Ctrl-C again to quit at once")
+		copiesAborted.Store(true)
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		close(signals)
+		cancel()
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metaDir holds exisort's own files inside the destination.
+const metaDir = ".exisort"
+
+// Journal is an append-only log of processed files, kept in <dest>/.exisort/journal.tsv.
+// When an interrupted import is restarted with the same command, files it transferred are skipped
+// without being read again. A run that completes clears it.
+type Journal struct {
+	mu   sync.Mutex
+	f    *os.File
+	w    *csv.Writer
+	run  string
+	done map[string]journalEntry
+}
+
+type journalEntry struct {
+	size  int64
+	mtime int64
+}
+
+var journal *Journal
+
+var journalHeader = []string{"time", "status", "source", "size", "mtime", "hash", "dest", "tag"}
+
+// journalFields is the number of columns a usable line has. Journals written before --tag have no tag column.
+const journalFields = 7
+
+// journalDone are the statuses of files that a resumed run doesn't need to look at again. Skipped files
+// and duplicates are looked at anew, the library may have changed.
+var journalDone = []string{"copied", "moved", "linked"}
+
+// OpenJournal loads the journal of dstRoot and opens it for appending. The journal belongs to the run
+// identified by run (the command line): if it was left by a different one, it is started over.
+func OpenJournal(dstRoot, run string) (*Journal, error) {
+	dir := filepath.Join(dstRoot, metaDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, "journal.tsv"), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	j := &Journal{f: f, w: csv.NewWriter(f), run: run, done: make(map[string]journalEntry)}
+	j.w.Comma = '\t'
+
+	r := csv.NewReader(f)
+	r.Comma = '\t'
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	sameRun := false
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || len(rec) < journalFields {
+			continue // A line cut short by a crash
+		}
+		if rec[1] == "run" {
+			sameRun = rec[2] == run
+			continue
+		}
+		if !slices.Contains(journalDone, rec[1]) {
+			continue
+		}
+		size, _ := strconv.ParseInt(rec[3], 10, 64)
+		mtime, _ := strconv.ParseInt(rec[4], 10, 64)
+		j.done[rec[2]] = journalEntry{size: size, mtime: mtime}
+	}
+
+	if !sameRun {
+		if err := j.start(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return j, nil
+}
+
+// start empties the journal for its run.
+func (j *Journal) start() error {
+	clear(j.done)
+	if err := j.f.Truncate(0); err != nil {
+		return err
+	}
+	j.w.Write(journalHeader)
+	j.w.Write([]string{time.Now().Format(time.RFC3339), "run", j.run, "", "", "", "", cfg.Tag})
+	j.w.Flush()
+	return j.w.Error()
+}
+
+// Clear forgets the files of a run that completed, so that the next one looks at all files again.
+func (j *Journal) Clear() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.start(); err != nil {
+		log.Warn("Failed to clear the journal: %v", err)
+	}
+}
+
+// Done reports whether the file was already processed by a previous run and hasn't changed since.
+func (j *Journal) Done(path string, info fs.FileInfo) bool {
+	if j == nil {
+		return false
+	}
+	e, ok := j.done[absPath(path)]
+	return ok && e.size == info.Size() && e.mtime == info.ModTime().UnixNano()
+}
+
+// Record appends a processed file. The line is flushed right away, so it survives a crash.
+func (j *Journal) Record(job FileJob, status, dest string) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.w.Write([]string{
+		time.Now().Format(time.RFC3339),
+		status,
+		absPath(job.Path),
+		strconv.FormatInt(job.Info.Size(), 10),
+		strconv.FormatInt(job.Info.ModTime().UnixNano(), 10),
+		fmt.Sprintf("%016x", job.Hash),
+		absPath(dest),
+		cfg.Tag,
+	})
+	j.w.Flush()
+	if err := j.w.Error(); err != nil {
+		log.Warn("Failed to write journal: %v", err)
+	}
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.f.Close()
+}
+
+// journalRun identifies the command being run: the working directory and the arguments.
+func journalRun() string {
+	return strings.Join(append([]string{absPath(".")}, os.Args[1:]...), " ")
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// lastRunFile records when each source was last imported completely (<dest>/.exisort/lastrun.tsv),
+// one "source, start time, filters" line per source. --since-last-run only looks at files changed after that.
+const lastRunFile = "lastrun.tsv"
+
+type lastRun struct {
+	Start   time.Time
+	Filters string // selectionFilters of the run
+}
+
+// selectionFilters sums up the options that decide which files of the source are imported. A run with
+// other filters may have left out files that --since-last-run would then never look at again.
+func selectionFilters() string {
+	exts := slices.Sorted(maps.Keys(cfg.Extensions))
+	s := fmt.Sprint(exts, cfg.Include, cfg.Exclude, cfg.MaxDepth, cfg.MinSizeBytes, cfg.After, cfg.Before,
+		cfg.FilterHook, cfg.Screenshots == "skip", cfg.PreferOrig, cfg.Sniff, cfg.SkipSystem, cfg.FollowSymlinks)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(s)))[:16]
+}
+
+// readLastRuns loads the last complete imports into dstRoot, by absolute source path.
+func readLastRuns(dstRoot string) map[string]lastRun {
+	runs := make(map[string]lastRun)
+	f, err := os.Open(filepath.Join(dstRoot, metaDir, lastRunFile))
+	if err != nil {
+		return runs
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = '\t'
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	recs, _ := r.ReadAll()
+	for _, rec := range recs {
+		if len(rec) < 2 {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, rec[1]); err == nil {
+			run := lastRun{Start: t}
+			if len(rec) > 2 {
+				run.Filters = rec[2]
+			}
+			runs[rec[0]] = run
+		}
+	}
+	return runs
+}
+
+// saveLastRun records start as the time of the last complete import of src, with the current filters.
+// The file is replaced in one step, so a crash leaves the previous version.
+func saveLastRun(dstRoot, src string, start time.Time) error {
+	runs := readLastRuns(dstRoot)
+	runs[absPath(src)] = lastRun{Start: start, Filters: selectionFilters()}
+
+	dir := filepath.Join(dstRoot, metaDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, lastRunFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := csv.NewWriter(tmp)
+	w.Comma = '\t'
+	for src, run := range runs {
+		w.Write([]string{src, run.Start.Format(time.RFC3339Nano), run.Filters})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, lastRunFile))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/levmv/exisort/exifdate"
+)
+
+// livePhotoExts are the stills a Live Photo video can belong to.
+// The video is always a QuickTime .mov with the same base name.
+var livePhotoExts = []string{"heic", "heif", "jpg", "jpeg"}
+
+// contentIDs caches the Apple ContentIdentifier of each file read so far:
+// both halves of a pair are scanned separately and each needs the other's.
+var contentIDs = struct {
+	sync.Mutex
+	m map[string]string
+}{m: make(map[string]string)}
+
+// contentID reads the identifier of a file, or gets it from the cache. The file is read without holding
+// the lock, so two scanners may both read it; they find the same.
+func contentID(path string) string {
+	contentIDs.Lock()
+	id, ok := contentIDs.m[path]
+	contentIDs.Unlock()
+	if ok {
+		return id
+	}
+
+	if f, err := os.Open(path); err == nil {
+		id, _ = exifdate.ContentIdentifier(f)
+		f.Close()
+	}
+	contentIDs.Lock()
+	contentIDs.m[path] = id
+	contentIDs.Unlock()
+	return id
+}
+
+// isLivePair reports whether photo and video carry the same ContentIdentifier.
+// Files without one are never paired: a same-named clip from another camera is a video of its own.
+func isLivePair(photo, video string) bool {
+	id := contentID(photo)
+	return id != "" && id == contentID(video)
+}
+
+// findLiveVideo returns the video half of the Live Photo at path, or "" if it isn't one.
+func findLiveVideo(path string) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if !slices.Contains(livePhotoExts, ext) {
+		return ""
+	}
+	dir, file := filepath.Split(path)
+	base := strings.TrimSuffix(strings.ToLower(file), "."+ext)
+	name, ok := listDir(filepath.Clean(dir))[base+".mov"]
+	if !ok {
+		return ""
+	}
+	if video := filepath.Join(dir, name); isLivePair(path, video) {
+		return video
+	}
+	return ""
+}
+
+// findLivePhoto returns the photo a .mov, rel in the source, belongs to, or "" if it is a video of its own.
+// Photos that won't be imported don't count, so their video is still imported alone.
+func findLivePhoto(metaSvc *MetadataService, path, rel string) string {
+	if !strings.EqualFold(filepath.Ext(path), ".mov") {
+		return ""
+	}
+	dir, file := filepath.Split(path)
+	base := strings.ToLower(strings.TrimSuffix(file, filepath.Ext(file)))
+	names := listDir(filepath.Clean(dir))
+	relDir := rel[:strings.LastIndex(rel, "/")+1]
+	for _, ext := range livePhotoExts {
+		name, ok := names[base+"."+ext]
+		if !ok || (!cfg.Sniff && !cfg.Extensions[ext]) {
+			continue
+		}
+		photo := filepath.Join(dir, name)
+		if isLivePair(photo, path) && importsPhoto(metaSvc, photo, relDir+name) {
+			return photo
+		}
+	}
+	return ""
+}
+
+// importsPhoto reports whether the photo of a Live Photo, rel in the source, is imported by this run
+// and takes its video along: it must pass the same filters as any other file. One the journal records
+// as imported took its video the first time.
+func importsPhoto(metaSvc *MetadataService, photo, rel string) bool {
+	info, err := os.Stat(photo)
+	if err != nil {
+		return false
+	}
+	if journal.Done(photo, info) {
+		return true
+	}
+	if excluded(rel) || !wantName(photo, rel) || !wantFile(photo, info) {
+		return false
+	}
+	if cfg.After.IsZero() && cfg.Before.IsZero() {
+		return true
+	}
+	f, err := os.Open(photo)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	return wantDate(photo, metaSvc.Resolve(f, info).Time)
+}
+
+// transferLiveVideo puts the video of a Live Photo next to the imported photo under the same base name,
+// so "IMG_0001.HEIC" + "IMG_0001.MOV" become "20240101_120000.HEIC" + "20240101_120000.MOV".
+// A video that is already there is treated as a duplicate.
+func transferLiveVideo(job FileJob, destPath string) {
+	video := job.LiveVideo
+	dst := strings.TrimSuffix(destPath, filepath.Ext(destPath)) + filepath.Ext(video)
+	if cfg.DryRun {
+		dryTransfer(video, dst, -1)
+		return
+	}
+	info, err := os.Stat(video)
+	if err != nil {
+		stats.IncError()
+		log.Error("Live Photo video %s: %v", video, err)
+		return
+	}
+	if existing, err := os.Stat(dst); err == nil {
+		if same, _ := areFilesDeepIdentical(video, dst, info.Size()); !same || existing.Size() != info.Size() {
+			log.Warn("Live Photo video %s already exists, keeping it", dst)
+		} else if cfg.Move {
+			os.Remove(video)
+		}
+		return
+	}
+
+	if err := placeCompanion(video, dst, info); err != nil {
+		stats.IncError()
+		log.Error("Failed to transfer Live Photo video %s: %v", video, err)
+		return
+	}
+	if !cfg.Symlink {
+		stats.AddBytes(info.Size())
+	}
+	log.Transfer(video, dst)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lockFile = "lock"
+
+// errLocked means another exisort is importing into the same library.
+var errLocked = errors.New("destination is in use")
+
+// lockWriteTime is how long a lock file may stay without its owner: an exisort that has just created it
+// is still writing the line. A lock file that is incomplete for longer was left by a crash.
+const lockWriteTime = time.Minute
+
+// lockDest makes sure only one import at a time writes into dstRoot, since two of them could
+// pick the same free name. The lock file (<dest>/.exisort/lock) holds our PID and host name;
+// a lock left behind by a process that no longer runs on this machine is taken over.
+// The returned function removes it again.
+func lockDest(dstRoot string) (func(), error) {
+	dir := filepath.Join(dstRoot, metaDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, lockFile)
+	host, _ := os.Hostname()
+	owner := fmt.Sprintf("%d\t%s\t%s\n", os.Getpid(), host, time.Now().Format(time.RFC3339))
+
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) || attempt > 0 {
+			return nil, err
+		}
+
+		data, err := os.ReadFile(path)
+		fields := strings.Split(strings.TrimSpace(string(data)), "\t")
+		if err != nil || len(fields) < 3 {
+			if info, serr := os.Stat(path); serr == nil && time.Since(info.ModTime()) > lockWriteTime {
+				log.Warn("Removing incomplete lock %s", path)
+				os.Remove(path)
+				continue
+			}
+			return nil, fmt.Errorf("%w: another exisort is starting to import into %s. If none is running, delete %s",
+				errLocked, dstRoot, path)
+		}
+		pid, _ := strconv.Atoi(fields[0])
+		if fields[1] == host && pid > 0 && !processAlive(pid) {
+			log.Warn("Removing stale lock %s", path)
+			os.Remove(path)
+			continue
+		}
+		return nil, fmt.Errorf("%w: exisort (PID %d on %s) has been importing into %s since %s. If it isn't running anymore, delete %s",
+			errLocked, pid, fields[1], dstRoot, fields[2], path)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -30,14 +31,16 @@ func InitLogger() {
 	log = &Logger{out: os.Stderr}
 }
 
-// Transfer logs a file copy/move operation.
-// It automatically detects Move vs Copy and Dry-Run from global config.
+// Transfer logs a file copy/move/link operation.
+// It automatically detects Move vs Copy vs Symlink and Dry-Run from global config.
 func (l *Logger) Transfer(src, dst string) {
 	label := "COPY"
 	color := ColorGreen
 
 	if cfg.Move {
 		label = "MOVE"
+	} else if cfg.Symlink {
+		label = "LINK"
 	}
 
 	if cfg.DryRun {
@@ -48,6 +51,38 @@ func (l *Logger) Transfer(src, dst string) {
 	l.print(color, label, "%s -> %s", src, dst)
 }
 
+// Mirror logs a copy into the --dest2 mirror.
+func (l *Logger) Mirror(src, dst string) {
+	if cfg.DryRun {
+		l.print(ColorGray, "DRY-MIRROR", "%s -> %s", src, dst)
+		return
+	}
+	l.print(ColorGreen, "MIRROR", "%s -> %s", src, dst)
+}
+
+// Clean logs a file removed or cloned by the clean command because the reference has a copy of it.
+func (l *Logger) Clean(action, path, copyPath string) {
+	label := strings.ToUpper(action)
+	color := ColorRed
+	if action == "reflink" {
+		color = ColorCyan
+	}
+	if cfg.DryRun {
+		label = "DRY-" + label
+		color = ColorGray
+	}
+	l.print(color, label, "%s (same as %s)", path, copyPath)
+}
+
+// Restore logs a file moved out of the trash by the restore command.
+func (l *Logger) Restore(src, dst string) {
+	if cfg.DryRun {
+		l.print(ColorGray, "DRY-RESTORE", "%s -> %s", src, dst)
+		return
+	}
+	l.print(ColorGreen, "RESTORE", "%s -> %s", src, dst)
+}
+
 // Duplicate logs a duplicate file encounter.
 // It automatically detects if we are Deleting (Move mode) or Skipping (Copy mode).
 func (l *Logger) Duplicate(path string) {
@@ -88,6 +123,11 @@ func (l *Logger) Info(format string, a ...any) {
 	l.print(ColorBlue, "INFO", format, a...)
 }
 
+// Card logs the progress of watch mode
+func (l *Logger) Card(format string, a ...any) {
+	l.print(ColorCyan, "CARD", format, a...)
+}
+
 // Error logs critical errors
 func (l *Logger) Error(format string, a ...any) {
 	l.print(ColorRed, "ERR", format, a...)
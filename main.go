@@ -7,22 +7,84 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
-	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
-	"syscall"
 	"time"
+
+	"github.com/levmv/exisort/exifdate"
 )
 
 type Config struct {
 	// Flags
-	Verbose   bool
-	DryRun    bool
-	Move      bool
-	DeepCheck bool
-	Conflict  string
-	Format    string
+	Verbose        bool
+	DryRun         bool
+	Move           bool
+	Symlink        bool
+	DeepCheck      bool
+	Verify         bool
+	XMPOverride    bool
+	PathDates      bool
+	SinceLastRun   bool
+	TakeoutCleanup bool
+	Journal        bool
+	Index          bool
+	Reflink        bool
+	Idle           bool
+	Conflict       string
+	SuffixEncoding string // hex, base36
+	Checksums      string // "", xattr, sidecar
+	HashAlgo       string // See hashAlgos
+	SuffixLength   int    // 0: all digits
+	Format         string
+	FormatVideo    string   // --format for videos, "" to use Format
+	FormatRaw      string   // --format for RAW files, "" to use Format
+	FormatShot     string   // --format-screenshot
+	Screenshots    string   // normal (also ""), separate, skip
+	Plan           string   // --plan format, "" to import
+	PreferOrig     bool     // --prefer-originals
+	MonthNames     []string // {monthname}, English if not set
+	FilesFrom      string
+	NoDateDir      string
+	PostHook       string
+	RunHook        string
+	FilterHook     string
+	Dest2          string
+	Tag            string
+	Zone           string
+	MotionPhoto    string
+	Bursts         string
+	CounterReset   string         // run (also ""), dir, day
+	GPXZone        *time.Location // Zone of camera times without one, for --gpx
+	GPXWrite       bool
+	StripTags      []string // ExifTool tags deleted from the imported files
+	ConvertHEIC    bool
+	KeepOriginal   bool
+	ShiftTime      time.Duration
+	VideoZone      *time.Location           // Zone for UTC video dates, --video-tz
+	VideoZoneAuto  bool                     // --video-tz auto
+	ShiftModels    map[string]time.Duration // By lower case camera model
+	Jobs           int
+	Limit          int
+	MaxDepth       int
+	FollowSymlinks bool
+	SkipSystem     bool
+	Sniff          bool
+	FixExt         bool
+	Sidecars       bool
+	LivePhotos     bool
+	BufferSize     int             // 0: let io.Copy decide
+	BWLimit        int64           // Bytes per second, 0: unlimited
+	MinFree        int64           // Bytes to keep free on the destination, 0: don't check
+	Preflight      bool            // Warn before the import if the source doesn't fit on the destination
+	Preserve       map[string]bool // mode, xattr, btime
 
+	Since        time.Time // Start of the last complete run with --since-last-run
+	After        time.Time // Wall times as UTC, see wantDate
+	Before       time.Time
 	Extensions   map[string]bool
+	Include      []string // Globs relative to the source, see matchGlob
+	Exclude      []string
 	MinSizeBytes int64
 }
 
@@ -33,32 +95,281 @@ var Version = "dev"
 // FileJob contains the "Fingerprint" of the source file
 type FileJob struct {
 	Path       string
+	Rel        string // See sourceFile.Rel
 	Info       fs.FileInfo
 	Date       time.Time
-	SourceHead []byte // First 64KB
+	DateSource string            // See DateInfo.Source
+	Meta       exifdate.Metadata // Camera and lens, for the naming tokens
+	SourceHead []byte            // First 64KB
 	Hash       uint64
+
+	MotionOffset int64    // Start of the embedded video in motion photos, 0 otherwise
+	Ext          string   // Extension matching the content with --fix-ext, "" to keep the original
+	Convert      string   // Extension of the format it is converted to with --convert, "" to import it as is
+	Screenshot   bool     // Named with --format-screenshot, see --screenshots
+	Sidecars     []string // .xmp/.aae/.thm files that go along with it
+	LiveVideo    string   // The .mov half of a Live Photo, stored under the same name
+	Burst        int      // 1-based position in its burst with --bursts, 0 if it isn't part of one
+	Existing     string   // The same file in the library, found by the index before reading the date
+	Sum          string   // Full content hash, with --conflict content-hash
+	Counter      int      // Number for the {counter} token, see numberJob
+	Geotagged    bool     // Meta has the position from --gpx rather than from the file
+
+	// Set for entries of an archive source, see sourceFile
+	FS   fs.FS
+	Name string
 }
 
-const defaultExtensions = "jpg,jpeg,png,heic,heif,mov,mp4,m4v,avi,arw,cr2,cr3,dng,nef"
+// size is the number of bytes that end up in the destination.
+// Stripped motion photos lose their trailing video.
+func (j FileJob) size() int64 {
+	if cfg.MotionPhoto == "strip" && j.MotionOffset > 0 {
+		return j.MotionOffset
+	}
+	return j.Info.Size()
+}
+
+const defaultExtensions = "jpg,jpeg,png,heic,heif,heics,hif,avif,jxl,tif,tiff,mov,mp4,m4v,avi,mkv,webm,arw,cr2,cr3,dng,nef,orf,rw2,pef"
 
 func main() {
+	os.Exit(run())
+}
+
+// run is the whole program and returns its exit code, so that deferred cleanup such as
+// releasing the destination lock happens before exiting.
+func run() int {
 	var rawExts string
 	var rawSizeKB int64
+	var rawDateTags string
+	var rawJPEGScanKB int
 
 	flag.BoolVar(&cfg.Verbose, "v", false, "Verbose logging")
 	flag.BoolVar(&cfg.DryRun, "dry-run", false, "Simulate operations without changes")
+	flag.Func("plan", "Print what would be done with every file as tsv or json (an object per line) and change nothing", func(v string) error {
+		if v != "tsv" && v != "json" {
+			return errors.New("must be tsv or json")
+		}
+		cfg.Plan = v
+		return nil
+	})
 	flag.BoolVar(&cfg.Move, "move", false, "Move files instead of copying")
+	flag.BoolVar(&cfg.Symlink, "symlink", false, "Create symlinks to the original files instead of copying them")
 	flag.BoolVar(&cfg.DeepCheck, "deep", false, "Verify content hash before skipping duplicates")
+	flag.BoolVar(&cfg.Verify, "verify", false, "Read back every copied file and compare its checksum with the source")
+	flag.BoolVar(&cfg.Reflink, "reflink", true, "Clone files instead of copying where the file system supports it (Btrfs, XFS, APFS)")
+	flag.Func("preserve", "Comma-separated attributes to keep on copies: mode, xattr, btime or all", parsePreserve)
+	flag.BoolVar(&cfg.XMPOverride, "xmp-override", false, "Prefer dates from .xmp sidecar files over embedded metadata")
+	flag.BoolVar(&cfg.PathDates, "path-dates", false, "Date files without metadata from folder names such as 2009/2009-07-Holiday before using the file time")
+	flag.BoolVar(&cfg.TakeoutCleanup, "takeout-cleanup", false, "Delete Google Takeout .json sidecars of imported files")
+	flag.BoolVar(&exifdate.UseGPSDate, "gps-date", true, "Use GPS date/time when no other EXIF date is present")
+	flag.BoolVar(&exifdate.Aggressive, "aggressive", false, "Search unknown formats for embedded EXIF (slower)")
+	flag.IntVar(&rawJPEGScanKB, "jpeg-scan-limit", exifdate.JPEGScanLimit/1024, "How far into a JPEG to look for EXIF, in KB (not counting skipped segments)")
+	flag.Func("date-layout", "Extra date `layout` for the native parser in Go syntax, e.g. \"2006/01/02 15:04:05\" (repeatable)", func(s string) error {
+		exifdate.ExtraLayouts = append(exifdate.ExtraLayouts, s)
+		return nil
+	})
+	flag.DurationVar(&cfg.ShiftTime, "shift-time", 0, "Add this to every capture date to correct a camera clock, e.g. 1h3m or -30s")
+	flag.Func("shift-model", "Clock correction for one camera `model=shift`, e.g. \"Canon EOS R5=1h3m\", instead of --shift-time (repeatable)", func(v string) error {
+		i := strings.LastIndex(v, "=")
+		if i < 0 {
+			return errors.New("must be model=shift")
+		}
+		shift, err := time.ParseDuration(v[i+1:])
+		if err != nil {
+			return err
+		}
+		if cfg.ShiftModels == nil {
+			cfg.ShiftModels = make(map[string]time.Duration)
+		}
+		cfg.ShiftModels[strings.ToLower(strings.TrimSpace(v[:i]))] = shift
+		return nil
+	})
+	flag.Func("profiles", "`file` with settings per camera model or make: format, shift and dest (a folder inside the destination)", loadProfiles)
+	flag.StringVar(&rawDateTags, "date-tags", "", "Comma-separated date tags in order of priority (e.g. CreateDate,DateTimeOriginal)")
 
-	flag.StringVar(&cfg.Conflict, "conflict", "rename", "Collision resolution: rename, skip, overwrite")
+	flag.StringVar(&cfg.Conflict, "conflict", "rename", "Collision resolution: rename, counter, skip, overwrite, overwrite-if-older, keep-larger, content-hash (name files by their content)")
+	flag.Func("suffix-encoding", "Digits of the hash appended to conflicting names: hex or base36 (default hex)", func(v string) error {
+		if v != "hex" && v != "base36" {
+			return errors.New("must be hex or base36")
+		}
+		cfg.SuffixEncoding = v
+		return nil
+	})
+	flag.IntVar(&cfg.SuffixLength, "suffix-length", 0, "Keep only this many digits of the hash appended to conflicting names (0: all)")
+	flag.Func("video-tz", "Zone for video creation times stored in UTC (most MP4/MOV): an offset like +02:00, a name like Europe/Rome, or auto (that of the photos taken around them)", func(v string) error {
+		if v == "auto" {
+			cfg.VideoZoneAuto = true
+			return nil
+		}
+		loc, err := parseZone(v)
+		if err != nil {
+			return errors.New("must be auto, an offset like +02:00 or a zone name like Europe/Rome")
+		}
+		cfg.VideoZone = loc
+		return nil
+	})
+	cfg.Zone = "original"
+	flag.Func("zone", "Wall time used for paths: original (zone of capture), local (default original)", func(v string) error {
+		if v != "original" && v != "local" {
+			return errors.New("must be original or local")
+		}
+		cfg.Zone = v
+		return nil
+	})
+	cfg.MotionPhoto = "keep"
+	flag.Func("motion-photo", "Video embedded in motion photos: keep, extract (also save as .mp4), strip (default keep)", func(v string) error {
+		if v != "keep" && v != "extract" && v != "strip" {
+			return errors.New("must be keep, extract or strip")
+		}
+		cfg.MotionPhoto = v
+		return nil
+	})
+	flag.StringVar(&cfg.NoDateDir, "no-date-dir", "", "Put files dated only by their modification time into this `folder` of the destination, e.g. _Unsorted")
+	flag.Func("bursts", "Number photos taken within the same second by sub-second time: seq (_001, _002...) or folder (seq inside a <name>_burst folder)", func(v string) error {
+		if v != "seq" && v != "folder" {
+			return errors.New("must be seq or folder")
+		}
+		cfg.Bursts = v
+		return nil
+	})
+	flag.Func("tag", "Name of this import, e.g. 2024-iceland-trip: recorded in the journal and receipt, and the {session} token of --format", func(v string) error {
+		if strings.ContainsAny(v, `/\`) || v == "." || v == ".." {
+			return errors.New("must be a plain folder name")
+		}
+		cfg.Tag = v
+		return nil
+	})
+	flag.Func("counter-reset", "Start {counter} again at 1 in every destination folder (dir) or for every day (day) instead of once per run", func(v string) error {
+		if v != "dir" && v != "day" && v != "run" {
+			return errors.New("must be run, dir or day")
+		}
+		cfg.CounterReset = v
+		return nil
+	})
+	flag.Func("month-names", "Comma-separated names of the 12 months for {monthname}, e.g. Januar,Februar,... (default English)", func(v string) error {
+		names := strings.Split(v, ",")
+		if len(names) != 12 {
+			return errors.New("must be 12 names")
+		}
+		cfg.MonthNames = names
+		return nil
+	})
 	flag.StringVar(&cfg.Format, "format", "{year}/{year}-{month}/{year}{month}{day}_{hour}{min}{sec}.{ext}", "Naming format")
+	flag.StringVar(&cfg.FormatVideo, "format-video", "", "Naming format for videos, e.g. 'Video/{year}/{year}{month}{day}_{hour}{min}{sec}.{ext}' (default: --format)")
+	flag.StringVar(&cfg.FormatRaw, "format-raw", "", "Naming format for RAW files (default: --format)")
+	flag.StringVar(&cfg.FormatShot, "format-screenshot", "Screenshots/{year}/{year}{month}{day}_{hour}{min}{sec}.{ext}", "Naming format for screenshots with --screenshots separate")
+	flag.BoolVar(&cfg.PreferOrig, "prefer-originals", false, "Skip photos edited with Lightroom, Snapseed etc. if the original is in the source too")
+	flag.Func("screenshots", "What to do with screenshots: normal (import like photos), separate (name with --format-screenshot), skip", func(v string) error {
+		switch v {
+		case "normal", "separate", "skip":
+			cfg.Screenshots = v
+			return nil
+		}
+		return errors.New("must be normal, separate or skip")
+	})
 
+	flag.BoolVar(&cfg.Journal, "journal", true, "Keep a journal in <dest>/.exisort to resume interrupted imports")
+	flag.BoolVar(&cfg.Index, "index", false, "Index the library in <dest>/.exisort to skip files already imported under another name (rebuilds the index)")
+	cfg.HashAlgo = "sha256"
+	flag.Func("hash-algo", "Hash for comparing and checksumming whole files: sha256, xxh64, blake3 or md5 (default sha256)", func(v string) error {
+		if hashAlgos[v] == nil {
+			return errors.New("must be sha256, xxh64, blake3 or md5")
+		}
+		cfg.HashAlgo = v
+		return nil
+	})
+	flag.Func("checksums", "Store the hash of every imported file: xattr (extended attribute) or sidecar (<file>.sha256)", func(v string) error {
+		if v != "xattr" && v != "sidecar" {
+			return errors.New("must be xattr or sidecar")
+		}
+		cfg.Checksums = v
+		return nil
+	})
+	flag.Func("gpx", "GPX `file` of a GPS logger: photos without a position get the one of the track at their capture time (repeatable)", loadGPX)
+	flag.Func("gpx-zone", "Zone of the camera clock for --gpx if the photos don't record it, e.g. +02:00 or Europe/Rome (default: this computer's)", func(v string) (err error) {
+		cfg.GPXZone, err = parseZone(v)
+		return err
+	})
+	flag.BoolVar(&cfg.GPXWrite, "gpx-write", false, "Write the positions found with --gpx into the imported files (needs ExifTool)")
+	flag.BoolFunc("strip-gps", "Remove the GPS position from the imported files, leaving the source untouched (needs ExifTool)", func(string) error {
+		cfg.StripTags = append(cfg.StripTags, stripGPSTags...)
+		return nil
+	})
+	flag.Func("strip-tags", "Comma-separated ExifTool tags or groups to remove from the imported files, e.g. SerialNumber,LensSerialNumber,OwnerName (needs ExifTool)", func(v string) error {
+		for t := range strings.SplitSeq(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				cfg.StripTags = append(cfg.StripTags, t)
+			}
+		}
+		return nil
+	})
+	flag.Func("convert", "Convert files on import: heic=jpg turns HEIC photos into JPEG (needs heif-convert, ImageMagick or sips)", func(v string) error {
+		if !strings.EqualFold(v, "heic=jpg") {
+			return errors.New("must be heic=jpg")
+		}
+		cfg.ConvertHEIC = true
+		return nil
+	})
+	flag.BoolVar(&cfg.KeepOriginal, "keep-original", false, "With --convert, also import the original next to the converted file")
+	flag.StringVar(&cfg.Dest2, "dest2", "", "Also copy every file into this second library, e.g. a backup drive")
+	flag.StringVar(&cfg.PostHook, "post-hook", "", "Shell `command` to run after each imported file, e.g. 'exiv2 -et {dest}' ({src} and {dest} are replaced)")
+	flag.StringVar(&cfg.RunHook, "run-hook", "", "Shell `command` to run after the import, with {src}, {dest}, {imported}, {duplicates} and {errors}")
+	flag.StringVar(&cfg.FilterHook, "filter-hook", "", "Program that decides which files to import: gets \"path<TAB>date<TAB>size<TAB>source\" lines, answers accept or reject")
+	flag.IntVar(&cfg.Jobs, "j", 1, "Number of files processed in parallel")
+	flag.BoolVar(&cfg.Idle, "idle", false, "Run with low CPU and I/O priority and pause between files")
+	flag.Func("buffer-size", "Copy buffer `size`, e.g. 4M (default: chosen by the OS)", func(v string) error {
+		n, err := parseSize(v)
+		cfg.BufferSize = int(n)
+		return err
+	})
+	flag.Func("bwlimit", "Limit reading to `rate` bytes per second in total, e.g. 50M", func(v string) error {
+		n, err := parseSize(v)
+		cfg.BWLimit = n
+		return err
+	})
+	cfg.MinFree = 256 << 20
+	flag.Func("min-free", "Stop before free space on the destination drops below `size` (default 256M, 0: don't check)", func(v string) error {
+		n, err := parseSize(v)
+		cfg.MinFree = n
+		return err
+	})
+	flag.BoolVar(&cfg.Preflight, "preflight", true, "Before importing, list the source and warn if it doesn't fit on the destination")
 	flag.StringVar(&rawExts, "extensions", defaultExtensions, "Comma-separated list of extensions to process")
+	flag.Func("include", "Only process files matching this `glob` relative to the source, ** matches any directories (repeatable)", func(v string) error {
+		return addPattern(&cfg.Include, v)
+	})
+	flag.Func("exclude", "Skip files and directories matching this `glob`, e.g. '**/Thumbnails/**' or '*_edited*' (repeatable)", func(v string) error {
+		return addPattern(&cfg.Exclude, v)
+	})
+	flag.IntVar(&cfg.MaxDepth, "max-depth", 0, "Descend at most N directory levels into the source (1: only its top level, 0: no limit)")
+	flag.BoolVar(&cfg.FollowSymlinks, "follow-symlinks", false, "Follow symbolic links to files and directories inside the source")
+	flag.BoolVar(&cfg.SkipSystem, "skip-system", true, "Skip system and NAS folders such as @eaDir, .Trash-1000 and $RECYCLE.BIN")
+	flag.Func("after", "Only import files taken on or after this `date` (YYYY-MM-DD [HH:MM[:SS]])", func(v string) (err error) {
+		cfg.After, err = parseDate(v)
+		return err
+	})
+	flag.Func("before", "Only import files taken before this `date` (YYYY-MM-DD [HH:MM[:SS]])", func(v string) (err error) {
+		cfg.Before, err = parseDate(v)
+		return err
+	})
+	flag.StringVar(&cfg.FilesFrom, "files-from", "", "Import the files listed in this `file` (- for stdin) instead of walking a source directory")
+	flag.BoolVar(&cfg.Sidecars, "sidecars", true, "Take .xmp, .aae and .thm sidecars along with their photo, renamed to match")
+	flag.BoolVar(&cfg.LivePhotos, "live-photos", true, "Keep the video of an Apple Live Photo next to its photo, named and dated by the photo")
+	flag.BoolVar(&cfg.Sniff, "sniff", false, "Select files by their content instead of their extension (opens every file)")
+	flag.BoolVar(&cfg.FixExt, "fix-ext", false, "Give files whose content doesn't match their extension the right one (e.g. HEIC named .jpg)")
+	flag.BoolVar(&cfg.SinceLastRun, "since-last-run", false, "Only look at files changed since the last complete import from the same source")
+	flag.IntVar(&cfg.Limit, "limit", 0, "Process only the first N matching files (0: all)")
 	flag.Int64Var(&rawSizeKB, "min-size", 32, "Minimum file size in KB to process")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Exisort: The safe photo organizer.\n\n")
-		fmt.Fprintf(os.Stderr, "Usage: exisort [flags] <source_dir> <destination_dir>\n\nFlags:\n")
+		fmt.Fprintf(os.Stderr, "Usage: exisort [flags] <source_dir|archive> <destination_dir>\n")
+		fmt.Fprintf(os.Stderr, "       exisort [flags] -files-from <list> <destination_dir>\n")
+		fmt.Fprintf(os.Stderr, "       exisort [flags] watch <destination_dir>\n")
+		fmt.Fprintf(os.Stderr, "       exisort [flags] preview <source_dir|archive> <destination_dir>\n")
+		fmt.Fprintf(os.Stderr, "       exisort [flags] date <file>...\n")
+		fmt.Fprintf(os.Stderr, "       exisort [flags] clean <target> -reference <library> [-action trash|delete|reflink]\n")
+		fmt.Fprintf(os.Stderr, "       exisort [flags] restore <target> [-match <pattern>] [-i]\n\nFlags:\n")
 		flag.PrintDefaults()
 	}
 
@@ -66,14 +377,26 @@ func main() {
 
 	if flag.NArg() >= 1 && flag.Arg(0) == "version" {
 		fmt.Println("exisort", Version)
-		os.Exit(0)
+		return 0
 	}
 
-	if flag.NArg() < 2 {
-		flag.Usage()
-		os.Exit(1)
-	}
 	cfg.MinSizeBytes = rawSizeKB * 1024
+	cfg.Jobs = max(cfg.Jobs, 1)
+	if cfg.BWLimit > 0 {
+		bwLimit = newThrottle(cfg.BWLimit)
+	}
+	exifdate.JPEGScanLimit = rawJPEGScanKB * 1024
+
+	if rawDateTags != "" {
+		var tags []string
+		for t := range strings.SplitSeq(rawDateTags, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tags = append(tags, t)
+			}
+		}
+		exifdate.DateTags = tags
+		dateTags = tags
+	}
 
 	cfg.Extensions = make(map[string]bool)
 	for e := range strings.SplitSeq(rawExts, ",") {
@@ -81,24 +404,274 @@ func main() {
 	}
 
 	InitLogger()
+
+	if flag.NArg() >= 1 && flag.Arg(0) == "date" {
+		metaSvc := &MetadataService{}
+		err := runDate(metaSvc, flag.Args()[1:])
+		metaSvc.Close()
+		if err != nil {
+			log.Error("%v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if flag.NArg() >= 1 && flag.Arg(0) == "clean" {
+		ctx, stop := handleInterrupts()
+		err := runClean(ctx, flag.Args()[1:])
+		stop()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Error("%v", err)
+			return 1
+		}
+		return 0
+	}
+
+	if flag.NArg() >= 1 && flag.Arg(0) == "restore" {
+		if err := runRestore(flag.Args()[1:]); err != nil {
+			log.Error("%v", err)
+			return 1
+		}
+		return 0
+	}
+
+	// A preview is a dry run that only sums up the destination folders
+	args := flag.Args()
+	preview := len(args) > 0 && args[0] == "preview"
+	if preview {
+		args = args[1:]
+		cfg.DryRun = true
+	}
+
+	// With --files-from and in watch mode, the only argument is the destination
+	var srcRoot, dstRoot string
+	watch := false
+	switch {
+	case len(args) == 2 && args[0] == "watch" && !preview:
+		dstRoot, watch = args[1], true
+	case cfg.FilesFrom != "" && len(args) == 1:
+		dstRoot = args[0]
+	case cfg.FilesFrom == "" && len(args) == 2:
+		srcRoot, dstRoot = args[0], args[1]
+	default:
+		flag.Usage()
+		return 1
+	}
+	if preview {
+		layout = newLayout(dstRoot)
+	}
+	if cfg.Plan != "" {
+		cfg.DryRun = true
+		plan = newPlan(os.Stdout, cfg.Plan == "json")
+	}
+
+	for name, f := range map[string]string{"format": cfg.Format, "format-video": cfg.FormatVideo, "format-raw": cfg.FormatRaw, "format-screenshot": cfg.FormatShot} {
+		if err := checkFormat(f); err != nil {
+			log.Error("--%s: %v", name, err)
+			return 1
+		}
+	}
+	for _, p := range profiles {
+		if err := checkFormat(p.Format); err != nil {
+			log.Error("--profiles [%s]: %v", p.Name, err)
+			return 1
+		}
+	}
+	if cfg.Move && cfg.Symlink {
+		log.Error("--move and --symlink can't be used together")
+		return 1
+	}
+	if cfg.GPXWrite && track == nil {
+		log.Error("--gpx-write needs --gpx")
+		return 1
+	}
+	if editsCopies() && cfg.Symlink {
+		log.Error("--gpx-write, --strip-gps and --strip-tags can't change files through --symlink")
+		return 1
+	}
+	if cfg.GPXWrite && slices.Contains(cfg.StripTags, stripGPSTags[0]) {
+		log.Error("--gpx-write and --strip-gps can't be used together")
+		return 1
+	}
+	if cfg.ConvertHEIC && cfg.Symlink {
+		log.Error("--convert and --symlink can't be used together")
+		return 1
+	}
+	if cfg.ConvertHEIC && !cfg.DryRun && heicConverter() == nil {
+		log.Error("--convert heic=jpg needs heif-convert (libheif), magick (ImageMagick) or sips (macOS)")
+		return 1
+	}
+	if cfg.Dest2 != "" && cfg.Symlink {
+		log.Error("--dest2 and --symlink can't be used together")
+		return 1
+	}
+	if srcRoot != "" && isArchive(srcRoot) {
+		if cfg.Symlink {
+			log.Error("--symlink can't link into an archive")
+			return 1
+		}
+		if cfg.Move {
+			log.Warn("--move has no effect on archives, files are copied")
+			cfg.Move = false
+		}
+	}
+	if organizesInPlace(srcRoot, dstRoot) {
+		if cfg.Symlink {
+			log.Error("--symlink can't link files into the folder they are in")
+			return 1
+		}
+		if !cfg.Move {
+			log.Info("The source is inside the destination: files are moved into place, not copied")
+			cfg.Move = true
+		}
+	}
+
+	if cfg.Idle {
+		if err := lowerPriority(); err != nil {
+			log.Warn("Could not lower priority: %v", err)
+		}
+	}
+
 	InitStats()
 
+	if cfg.SinceLastRun && srcRoot != "" {
+		switch run, ok := readLastRuns(dstRoot)[absPath(srcRoot)]; {
+		case !ok:
+			log.Info("No complete import from %s yet, looking at all files", srcRoot)
+		case run.Filters != selectionFilters():
+			log.Info("The last import from %s selected files differently, looking at all files", srcRoot)
+		default:
+			cfg.Since = run.Start
+			log.Info("Looking only at files changed since %s", run.Start.Format(time.DateTime))
+		}
+	}
+
+	// A dry run only reads the library, so it may look at one that is being imported into
+	if !cfg.DryRun {
+		for _, root := range []string{dstRoot, cfg.Dest2} {
+			if root == "" {
+				continue
+			}
+			unlock, err := lockDest(root)
+			if err != nil {
+				log.Error("%v", err)
+				return 1
+			}
+			defer unlock()
+		}
+	}
+
 	metaSvc := &MetadataService{}
 	defer metaSvc.Close()
 
-	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer stop()
-	defer func() {
-		log.ClearStatus()
-		stats.PrintSummary()
-	}()
-
-	if err := Run(ctx, metaSvc, flag.Arg(0), flag.Arg(1)); err != nil {
-		if errors.Is(err, context.Canceled) {
-			log.Warn("Interrupted by user")
+	// A tree of links is only a preview: it must not mark files as imported
+	if cfg.Journal && !cfg.DryRun && !cfg.Symlink {
+		j, err := OpenJournal(dstRoot, journalRun())
+		if err != nil {
+			log.Warn("Journal disabled: %v", err)
 		} else {
+			journal = j
+			defer journal.Close()
+		}
+	}
+
+	// An existing index is always used and kept up to date; -index (re)builds it
+	if cfg.Symlink {
+		// Links never go into the library index either
+	} else if x, err := OpenIndex(dstRoot, cfg.Index && !cfg.DryRun); err != nil {
+		log.Warn("Library index disabled: %v", err)
+	} else if x != nil {
+		destIndex = x
+		defer destIndex.Close()
+	}
+
+	if cfg.FilterHook != "" {
+		h, err := StartFilterHook(cfg.FilterHook)
+		if err != nil {
+			log.Error("Filter hook: %v", err)
+			return 1
+		}
+		filterHook = h
+		defer filterHook.Close()
+	}
+
+	ctx, stop := handleInterrupts()
+	defer stop()
+	if watch {
+		if err := runWatch(ctx, metaSvc, dstRoot); err != nil && !errors.Is(err, context.Canceled) {
 			log.Error("Failed: %v", err)
-			os.Exit(1)
+			return 1
+		}
+		return 0
+	}
+
+	startReceipt(srcRoot, dstRoot)
+	err := Run(ctx, metaSvc, srcRoot, dstRoot)
+	if err := receipt.Save(); err != nil {
+		log.Warn("Failed to write the receipt: %v", err)
+	}
+	log.ClearStatus()
+	if layout != nil {
+		layout.Print(os.Stdout)
+	}
+	stats.PrintSummary()
+	printMirrorSummary()
+	switch {
+	case errors.Is(err, context.Canceled):
+		log.Warn("Interrupted by user")
+		return 0
+	case errors.Is(err, errDiskFull):
+		log.Error("Stopped: less than %s would be left free on the destination (--min-free)", formatBytes(cfg.MinFree))
+		return 1
+	case err != nil:
+		log.Error("Failed: %v", err)
+		return 1
+	}
+	journal.Clear()
+
+	runHook(cfg.RunHook, runSummaryVars(srcRoot, dstRoot))
+
+	// Only a run that saw every file without errors can be the base for --since-last-run
+	if srcRoot != "" && !cfg.DryRun && !cfg.Symlink && cfg.Limit == 0 && stats.Errors.Load() == 0 &&
+		(mirrorStats == nil || mirrorStats.Errors.Load() == 0) {
+		if err := saveLastRun(dstRoot, srcRoot, stats.StartTime); err != nil {
+			log.Warn("Failed to record the run: %v", err)
+		}
+	}
+	return 0
+}
+
+// parseSize parses a byte count with an optional K, M or G suffix (powers of 1024), e.g. "50M".
+func parseSize(v string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	mult := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		mult = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		mult = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", v)
+	}
+	return n * mult, nil
+}
+
+// rangeLayouts are accepted by -after and -before.
+var rangeLayouts = []string{"2006-01-02", "2006-01-02 15:04", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02T15:04:05"}
+
+// parseDate parses a -after/-before value. It is a wall time, so it is kept as UTC.
+func parseDate(v string) (time.Time, error) {
+	for _, layout := range rangeLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+			return t, nil
 		}
 	}
+	return time.Time{}, fmt.Errorf("invalid date %q (want YYYY-MM-DD [HH:MM[:SS]])", v)
 }
@@ -3,7 +3,11 @@ package main
 import (
 	"errors"
 	"io/fs"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +18,7 @@ import (
 var (
 	dateTags = []string{
 		"CreationDate",
+		"SubSecDateTimeOriginal",
 		"DateTimeOriginal",
 		"ContentCreateDate",
 		"CreateDate",
@@ -33,65 +38,161 @@ var (
 )
 
 type MetadataService struct {
-	et *exiftool.Exiftool
-	mu sync.Mutex
+	et       *exiftool.Exiftool
+	etGroups *exiftool.Exiftool // Prints group names, for hasTags
+	mu       sync.Mutex
 }
 
-// Close cleans up the ExifTool process if it was started.
+// Close cleans up the ExifTool processes that were started.
 func (s *MetadataService) Close() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if s.et != nil {
-		s.et.Close()
-		s.et = nil
+	for _, et := range []**exiftool.Exiftool{&s.et, &s.etGroups} {
+		if *et != nil {
+			(*et).Close()
+			*et = nil
+		}
 	}
 }
 
 // ensureExifTool lazily initializes the ExifTool instance.
 func (s *MetadataService) ensureExifTool() (*exiftool.Exiftool, error) {
+	return s.startExifTool(&s.et)
+}
+
+func (s *MetadataService) startExifTool(et **exiftool.Exiftool, opts ...func(*exiftool.Exiftool) error) (*exiftool.Exiftool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.et != nil {
-		return s.et, nil
+	if *et != nil {
+		return *et, nil
 	}
 
-	et, err := exiftool.NewExiftool()
+	e, err := exiftool.NewExiftool(opts...)
 	if err != nil {
 		return nil, err
 	}
-	s.et = et
-	return s.et, nil
+	*et = e
+	return e, nil
+}
+
+// DateInfo tells where the date of a file came from.
+type DateInfo struct {
+	Time   time.Time
+	Source string // native, exiftool, xmp-sidecar, takeout, path or mtime
+	Tag    string // Tag, chunk or sidecar file the value was read from
+	Raw    string // The value as stored, if it was text
+
+	Meta exifdate.Metadata // Camera and lens tags read natively, whichever source the date came from
+}
+
+// logDate tells where the date of a file came from (verbose only).
+func logDate(path string, d DateInfo) {
+	if d.Tag == "" {
+		log.Info("Dated %s: %s (%s)", path, d.Time.Format(time.DateTime), d.Source)
+		return
+	}
+	log.Info("Dated %s: %s (%s, %s)", path, d.Time.Format(time.DateTime), d.Source, d.Tag)
+}
+
+// Resolve finds the date of a file, trying the sources in order of trust.
+func (s *MetadataService) Resolve(f *os.File, info fs.FileInfo) DateInfo {
+	// The native parser also reads the camera tags, which are wanted even if the date comes from elsewhere
+	md, err := exifdate.GetMetadata(f)
+	d := s.resolveDate(f, info, md, err)
+	d.Meta = md
+	localizeVideo(f.Name(), &d)
+	shiftDate(&d)
+	return d
+}
+
+// shiftDate corrects the clock of the camera a file came from: by its --shift-model rule, the shift of its
+// --profiles entry, or else --shift-time. File times are left alone, they come from the computer that wrote the file.
+func shiftDate(d *DateInfo) {
+	if d.Source == "mtime" || d.Time.IsZero() {
+		return
+	}
+	shift, ok := cfg.ShiftModels[strings.ToLower(strings.TrimSpace(d.Meta.Model))]
+	if p := profileFor(d.Meta); !ok && p != nil && p.HasShift {
+		shift, ok = p.Shift, true
+	}
+	if !ok {
+		shift = cfg.ShiftTime
+	}
+	d.Time = d.Time.Add(shift)
 }
 
-func (s *MetadataService) GetTime(f *os.File, info fs.FileInfo) time.Time {
-	// 1. Try native Go parser (fast, zero-alloc)
-	t, err := exifdate.Get(f)
+func (s *MetadataService) resolveDate(f *os.File, info fs.FileInfo, md exifdate.Metadata, err error) DateInfo {
+	// 0. Sidecar wins over embedded metadata if the user asked for it
+	if cfg.XMPOverride {
+		if d, found := sidecarTime(f.Name()); found {
+			return d
+		}
+	}
+
+	// 1. Try native Go parser (fast)
 	if err == nil {
-		return t
+		return DateInfo{Time: md.Date, Source: "native", Tag: md.DateTag, Raw: md.DateRaw}
 	}
 
 	// 2. Fallback to ExifTool if format is unsupported (e.g., complex Video)
 	if errors.Is(err, exifdate.ErrUnsupported) {
-		if tFallback, found := s.fallbackExifTool(f.Name()); found {
-			return tFallback
+		if d, found := s.fallbackExifTool(f.Name()); found {
+			return d
+		}
+	}
+
+	// 3. XMP sidecar written by a RAW workflow (Lightroom, darktable...)
+	if !cfg.XMPOverride {
+		if d, found := sidecarTime(f.Name()); found {
+			return d
+		}
+	}
+
+	// 4. Google Takeout strips EXIF from many files, but keeps the date in a .json next to them
+	if d, found := takeoutTime(f.Name()); found {
+		return d
+	}
+
+	// 5. Folders named after the date, if the user trusts them
+	if cfg.PathDates {
+		if d, found := pathTime(f.Name()); found {
+			return d
+		}
+	}
+	return DateInfo{Time: info.ModTime(), Source: "mtime"}
+}
+
+// sidecarTime looks for "IMG_0001.CR2.xmp" and "IMG_0001.xmp" next to the file.
+func sidecarTime(path string) (DateInfo, bool) {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+
+	for _, candidate := range []string{path, base} {
+		for _, ext := range []string{".xmp", ".XMP"} {
+			data, err := os.ReadFile(candidate + ext)
+			if err != nil {
+				continue
+			}
+			if t, err := exifdate.ParseXMP(data); err == nil {
+				return DateInfo{Time: t, Source: "xmp-sidecar", Tag: filepath.Base(candidate + ext)}, true
+			}
 		}
 	}
-	return info.ModTime()
+	return DateInfo{}, false
 }
 
-func (s *MetadataService) fallbackExifTool(path string) (time.Time, bool) {
+func (s *MetadataService) fallbackExifTool(path string) (DateInfo, bool) {
 	et, err := s.ensureExifTool()
 	if err != nil {
 		// ExifTool likely not installed or failed to start
 		// TODO: probably log something in verbose mode?
-		return time.Time{}, false
+		return DateInfo{}, false
 	}
 
 	fileInfos := et.ExtractMetadata(path)
 
 	if len(fileInfos) == 0 || fileInfos[0].Err != nil {
-		return time.Time{}, false
+		return DateInfo{}, false
 	}
 
 	fields := fileInfos[0].Fields
@@ -101,12 +202,91 @@ func (s *MetadataService) fallbackExifTool(path string) (time.Time, bool) {
 			if dateStr, ok := val.(string); ok {
 				for _, layout := range dateLayouts {
 					if parsedTime, err := time.Parse(layout, dateStr); err == nil {
-						return parsedTime, true
+						return DateInfo{Time: parsedTime, Source: "exiftool", Tag: key, Raw: dateStr}, true
 					}
 				}
 			}
 		}
 	}
 
-	return time.Time{}, false
+	return DateInfo{}, false
+}
+
+// WriteTags sets tags of path with ExifTool; an empty value deletes the tag, or a whole group such as "GPS:All".
+// It reports whether the file changed: if there are only tags to delete and the file has none of them,
+// it is left alone, as ExifTool would refuse to write it.
+func (s *MetadataService) WriteTags(path string, tags map[string]string) (bool, error) {
+	if !slices.ContainsFunc(slices.Collect(maps.Values(tags)), func(v string) bool { return v != "" }) {
+		present, err := s.hasTags(path, slices.Collect(maps.Keys(tags)))
+		if err != nil || !present {
+			return false, err
+		}
+	}
+
+	et, err := s.ensureExifTool()
+	if err != nil {
+		return false, err
+	}
+	md := exiftool.EmptyFileMetadata()
+	md.File = path
+	for k, v := range tags {
+		md.SetString(k, v)
+	}
+
+	mds := []exiftool.FileMetadata{md}
+	et.WriteMetadata(mds)
+	if err := mds[0].Err; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// hasTags reports whether path has any of the tags, named as for WriteTags: "SerialNumber", "GPS:All",
+// "Keys:GPSCoordinates". The group can be of family 0 (EXIF, XMP, QuickTime) or 1 (GPS, XMP-dc, Keys).
+func (s *MetadataService) hasTags(path string, tags []string) (bool, error) {
+	et, err := s.startExifTool(&s.etGroups, exiftool.PrintGroupNames("0:1"))
+	if err != nil {
+		return false, err
+	}
+	md := et.ExtractMetadata(path)[0]
+	if md.Err != nil {
+		return false, md.Err
+	}
+	// Keys are "EXIF:GPS:GPSLatitude", "XMP:XMP-exif:GPSLatitude"..., with a single group if both are the same
+	for key := range md.Fields {
+		f := strings.SplitN(key, ":", 3)
+		switch {
+		case len(f) == 2:
+			f = []string{f[0], f[0], f[1]}
+		case len(f) != 3:
+			continue // SourceFile
+		}
+		if f[0] == "File" || f[0] == "ExifTool" || f[0] == "Composite" {
+			continue // Not stored in the file's metadata, or derived from other tags
+		}
+		for _, t := range tags {
+			if tagMatches(t, f[0], f[1], f[2]) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// tagMatches reports whether the tag name, of groups group0 and group1, is one that spec names.
+func tagMatches(spec, group0, group1, name string) bool {
+	group, tag, ok := strings.Cut(spec, ":")
+	if !ok {
+		group, tag = "", spec
+	}
+	if group != "" && !strings.EqualFold(group, group0) && !strings.EqualFold(group, group1) {
+		return false
+	}
+	switch {
+	case strings.EqualFold(tag, "All"):
+		return true
+	case strings.EqualFold(tag, "Geotag"):
+		return strings.HasPrefix(name, "GPS") // Geotag stands for the GPS tags of the group
+	}
+	return strings.EqualFold(tag, name)
 }
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// mirrorStats counts the --dest2 mirror separately from the main library.
+var mirrorStats *Statistics
+
+// mirrorOne copies job into the --dest2 library, resolving name conflicts there on its own.
+// The mirror only ever gets copies, even with --move. It reports whether the file is safely there,
+// so the source isn't moved away before it is.
+func mirrorOne(job FileJob, originalDest string) bool {
+	dest, duplicate := resolveConflict(job, originalDest)
+	switch {
+	case duplicate != "":
+		mirrorStats.IncDuplicate()
+		log.Info("Mirror already has %s as %s", job.Path, duplicate)
+		return true
+	case dest == "":
+		return true // --conflict skip
+	}
+
+	if cfg.DryRun {
+		log.Mirror(job.Path, dest)
+		plan.Add("mirror", job.Path, dest)
+		return true
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		mirrorStats.IncError()
+		log.Error("Mkdir failed for %s: %v", dest, err)
+		return false
+	}
+
+	var err error
+	if job.Convert != "" {
+		err = convertJob(job, dest)
+	} else {
+		err = copySource(job, dest)
+	}
+	switch {
+	case errors.Is(err, errAborted):
+		return false
+	case err != nil:
+		mirrorStats.IncError()
+		log.Error("Mirror failed for %s: %v", job.Path, err)
+		return false
+	}
+	noteWritten(dest)
+	sum, size := job.Sum, job.size()
+	if job.Convert != "" {
+		sum = ""
+		if info, err := os.Stat(dest); err == nil {
+			size = info.Size()
+		}
+	}
+	recordSum(dest, sum, size)
+	mirrorStats.IncProcessed()
+	mirrorStats.AddBytes(job.size())
+	log.Mirror(job.Path, dest)
+
+	for _, sc := range job.Sidecars {
+		mirrorCompanion(sc, sidecarDest(job.Path, sc, dest))
+	}
+	if job.LiveVideo != "" {
+		mirrorCompanion(job.LiveVideo, strings.TrimSuffix(dest, filepath.Ext(dest))+filepath.Ext(job.LiveVideo))
+	}
+	if cfg.MotionPhoto == "extract" && job.MotionOffset > 0 && job.Convert == "" {
+		extractMotionVideo(job, dest, dest)
+	}
+	return true
+}
+
+// mirrorCompanion copies a sidecar or Live Photo video next to the mirrored file, unless it is there already.
+func mirrorCompanion(src, dst string) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return
+	}
+	if _, err := os.Stat(dst); err == nil {
+		return
+	}
+	if err := copyFile(src, dst, info, nil); err != nil {
+		mirrorStats.IncError()
+		log.Error("Mirror failed for %s: %v", src, err)
+		return
+	}
+	noteWritten(dst)
+	mirrorStats.AddBytes(info.Size())
+}
+
+// printMirrorSummary prints the statistics of the mirror after those of the library.
+func printMirrorSummary() {
+	if mirrorStats == nil {
+		return
+	}
+	mirrorStats.FilesScanned.Store(stats.FilesScanned.Load())
+	fmt.Fprintf(os.Stderr, "Mirror %s:\n", cfg.Dest2)
+	mirrorStats.PrintSummary()
+}
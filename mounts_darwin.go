@@ -0,0 +1,19 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// listMounts returns the volumes in /Volumes, where macOS mounts cards and external drives.
+func listMounts() ([]string, error) {
+	entries, err := os.ReadDir("/Volumes")
+	if err != nil {
+		return nil, err
+	}
+	var mounts []string
+	for _, e := range entries {
+		mounts = append(mounts, filepath.Join("/Volumes", e.Name()))
+	}
+	return mounts, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// cardFilesystems are the file systems memory cards and camera drives come with.
+// Other mounts (system, network, FUSE...) are never looked at, so a hung NAS can't block the watch.
+var cardFilesystems = map[string]bool{
+	"vfat": true, "msdos": true, "exfat": true,
+	"ntfs": true, "ntfs3": true, "fuseblk": true,
+	"hfsplus": true, "apfs": true,
+}
+
+// listMounts returns the mount points of removable-looking file systems from /proc/self/mounts.
+func listMounts() ([]string, error) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 || !cardFilesystems[fields[2]] {
+			continue
+		}
+		mounts = append(mounts, unescapeMount(fields[1]))
+	}
+	return mounts, sc.Err()
+}
+
+// unescapeMount decodes the octal escapes of /proc/self/mounts, e.g. "\040" for a space.
+func unescapeMount(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
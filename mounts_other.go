@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "errors"
+
+func listMounts() ([]string, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,22 @@
+package main
+
+import "golang.org/x/sys/windows"
+
+// listMounts returns the removable drives, e.g. "E:\".
+func listMounts() ([]string, error) {
+	drives, err := windows.GetLogicalDrives()
+	if err != nil {
+		return nil, err
+	}
+	var mounts []string
+	for i := range 26 {
+		if drives&(1<<i) == 0 {
+			continue
+		}
+		root := string(rune('A'+i)) + `:\`
+		if windows.GetDriveType(windows.StringToUTF16Ptr(root)) == windows.DRIVE_REMOVABLE {
+			mounts = append(mounts, root)
+		}
+	}
+	return mounts, nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pathYear matches a folder name starting with a year, optionally followed by month and day:
+// "2009", "2009-07-Holiday", "2009_07_14 Beach", "20090714".
+var pathYear = regexp.MustCompile(`^((?:19|20)\d\d)(?:[-_. ]?(0[1-9]|1[0-2])(?:[-_. ]?(0[1-9]|[12]\d|3[01]))?)?(?:\D|$)`)
+
+// pathPart matches a month or day folder below a year folder: "07", "07-July", "14 Beach".
+var pathPart = regexp.MustCompile(`^(\d\d)(?:\D|$)`)
+
+// pathTime infers a date from the folders of path (--path-dates), for archives that are already
+// sorted by hand, such as "2009/2009-07-Holiday/scan.jpg" or "2009/07/14/scan.jpg".
+// The deepest folder naming a year wins; missing month and day count as the first.
+func pathTime(path string) (DateInfo, bool) {
+	var year, month, day int
+	var tag string
+	chained := false // The previous folder was part of the date
+	for dir := range strings.SplitSeq(filepath.ToSlash(filepath.Dir(path)), "/") {
+		if m := pathYear.FindStringSubmatch(dir); m != nil {
+			year, _ = strconv.Atoi(m[1])
+			month, _ = strconv.Atoi(m[2])
+			day, _ = strconv.Atoi(m[3])
+			tag, chained = dir, true
+			continue
+		}
+		m := pathPart.FindStringSubmatch(dir)
+		if !chained || m == nil || day != 0 {
+			chained = false
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		switch {
+		case month == 0 && n >= 1 && n <= 12:
+			month = n
+		case month != 0 && n >= 1 && n <= 31:
+			day = n
+		default:
+			chained = false
+			continue
+		}
+		tag += "/" + dir
+	}
+	if year == 0 || year > time.Now().Year() {
+		return DateInfo{}, false
+	}
+
+	t := time.Date(year, time.Month(max(month, 1)), max(day, 1), 0, 0, 0, 0, time.Local)
+	if t.Day() != max(day, 1) {
+		return DateInfo{}, false // "2009/02/31"
+	}
+	return DateInfo{Time: t, Source: "path", Tag: tag}, true
+}
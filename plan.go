@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// Plan prints what an import would do with every file (--plan), as the dry run finds out.
+// Destinations are the ones the import would pick, after resolving name conflicts.
+type Plan struct {
+	mu  sync.Mutex
+	tsv *csv.Writer
+	enc *json.Encoder
+
+	// The destinations given away so far, so that later files find them taken
+	// like in a real import: conflicts are renamed, the same content is a duplicate
+	claimed map[string]FileJob
+}
+
+type PlanEntry struct {
+	Action string `json:"action"` // copy, move, link, duplicate, skip or mirror
+	Source string `json:"source"`
+	Dest   string `json:"dest"` // For duplicates the file already there, for skips the name that is taken
+}
+
+var plan *Plan
+
+// newPlan writes a plan to w, as JSON objects one per line, or else as tab-separated values with a header line.
+func newPlan(w io.Writer, asJSON bool) *Plan {
+	if asJSON {
+		return &Plan{enc: json.NewEncoder(w), claimed: make(map[string]FileJob)}
+	}
+	p := &Plan{tsv: csv.NewWriter(w), claimed: make(map[string]FileJob)}
+	p.tsv.Comma = '\t'
+	p.tsv.Write([]string{"action", "source", "dest"})
+	p.tsv.Flush()
+	return p
+}
+
+// Add records what would happen to the file at src.
+func (p *Plan) Add(action, src, dest string) {
+	if p == nil {
+		return
+	}
+	e := PlanEntry{Action: action, Source: absPath(src), Dest: absPath(dest)}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.enc != nil {
+		p.enc.Encode(e)
+		return
+	}
+	p.tsv.Write([]string{e.Action, e.Source, e.Dest})
+	p.tsv.Flush()
+}
+
+// claim marks dest as taken by job.
+func (p *Plan) claim(job FileJob, dest string) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.claimed[dest] = job
+	p.mu.Unlock()
+}
+
+// claimant returns the job the plan gave path to, if any.
+func (p *Plan) claimant(path string) (FileJob, bool) {
+	if p == nil {
+		return FileJob{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job, ok := p.claimed[path]
+	return job, ok
+}
+
+// transferAction names what the import does with a file it takes: copy, move or link.
+func transferAction() string {
+	switch {
+	case cfg.Move:
+		return "move"
+	case cfg.Symlink:
+		return "link"
+	}
+	return "copy"
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// preserveKinds are the attributes -preserve can copy besides the modification time.
+var preserveKinds = []string{"mode", "xattr", "btime"}
+
+// parsePreserve parses the -preserve list into cfg.Preserve.
+func parsePreserve(s string) error {
+	cfg.Preserve = make(map[string]bool)
+	for kind := range strings.SplitSeq(s, ",") {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		switch {
+		case kind == "":
+		case kind == "all":
+			for _, k := range preserveKinds {
+				cfg.Preserve[k] = true
+			}
+		case slices.Contains(preserveKinds, kind):
+			cfg.Preserve[kind] = true
+		default:
+			return fmt.Errorf("unknown attribute %q (want %s or all)", kind, strings.Join(preserveKinds, ", "))
+		}
+	}
+	return nil
+}
+
+// preserveAttrs copies the attributes selected with -preserve from src to dst.
+// Failures are only warned about: the data itself is already safely copied.
+func preserveAttrs(src, dst string, srcInfo fs.FileInfo) {
+	if cfg.Preserve["mode"] {
+		if err := os.Chmod(dst, srcInfo.Mode().Perm()); err != nil {
+			warnPreserve("mode", dst, err)
+		}
+	}
+	if cfg.Preserve["xattr"] {
+		if err := copyXattrs(src, dst); err != nil {
+			warnPreserve("xattrs", dst, err)
+		}
+	}
+	if cfg.Preserve["btime"] {
+		if err := setBirthTime(dst, srcInfo); err != nil {
+			warnPreserve("creation time", dst, err)
+		}
+	}
+}
+
+var unsupportedWarned sync.Map
+
+// warnPreserve reports a failed attribute copy. Platforms and file systems
+// that lack an attribute altogether are only reported once.
+func warnPreserve(what, dst string, err error) {
+	if errors.Is(err, errors.ErrUnsupported) {
+		if _, seen := unsupportedWarned.LoadOrStore(what, true); !seen {
+			log.Warn("Cannot preserve %s: not supported by this platform or file system", what)
+		}
+		return
+	}
+	log.Warn("Failed to preserve %s of %s: %v", what, dst, err)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// keepXattr copies everything (Finder info, tags, quarantine...) except
+// provenance, which only the system may set.
+func keepXattr(name string) bool {
+	return name != "com.apple.provenance"
+}
+
+// setBirthTime sets the creation date shown by Finder to the one of the source.
+func setBirthTime(dst string, srcInfo fs.FileInfo) error {
+	st, ok := srcInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	ts := unix.Timespec{Sec: st.Birthtimespec.Sec, Nsec: st.Birthtimespec.Nsec}
+	attrs := unix.Attrlist{Bitmapcount: unix.ATTR_BIT_MAP_COUNT, Commonattr: unix.ATTR_CMN_CRTIME}
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(&ts)), unsafe.Sizeof(ts))
+	return unix.Setattrlist(dst, &attrs, buf, 0)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+)
+
+// keepXattr limits copying to the user namespace. The other namespaces hold
+// ACLs and security labels that belong to the destination system.
+func keepXattr(name string) bool {
+	return strings.HasPrefix(name, "user.")
+}
+
+// setBirthTime is not possible on Linux: statx reports the birth time, but nothing sets it.
+func setBirthTime(dst string, srcInfo fs.FileInfo) error {
+	return errors.ErrUnsupported
+}
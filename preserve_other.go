@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+)
+
+func copyXattrs(src, dst string) error {
+	return errors.ErrUnsupported
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func setXattr(path, name string, value []byte) error {
+	return errors.ErrUnsupported
+}
+
+func setBirthTime(dst string, srcInfo fs.FileInfo) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"syscall"
+)
+
+func copyXattrs(src, dst string) error {
+	return errors.ErrUnsupported
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func setXattr(path, name string, value []byte) error {
+	return errors.ErrUnsupported
+}
+
+// setBirthTime sets the "Date created" of dst to the one of the source.
+func setBirthTime(dst string, srcInfo fs.FileInfo) error {
+	d, ok := srcInfo.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return errors.ErrUnsupported
+	}
+	p, err := syscall.UTF16PtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	h, err := syscall.CreateFile(p, syscall.FILE_WRITE_ATTRIBUTES, syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(h)
+	return syscall.SetFileTime(h, &d.CreationTime, nil, nil)
+}
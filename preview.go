@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// destLayout sums up where a dry run would put the files, by destination folder (exisort preview).
+type destLayout struct {
+	mu   sync.Mutex
+	root string
+	dirs map[string]*layoutDir // By path relative to root; each counts its subfolders too
+}
+
+type layoutDir struct {
+	files, bytes int64
+}
+
+// layout collects the destination tree instead of logging every file, nil if not previewing.
+var layout *destLayout
+
+func newLayout(root string) *destLayout {
+	return &destLayout{root: root, dirs: make(map[string]*layoutDir)}
+}
+
+// dryTransfer shows a transfer that a dry run only pretends to do: in the --plan or the layout of a preview,
+// else in the log. A size below 0 is looked up from src.
+func dryTransfer(src, dst string, size int64) {
+	plan.Add(transferAction(), src, dst)
+	if layout == nil {
+		if plan == nil {
+			log.Transfer(src, dst)
+		}
+		return
+	}
+	if size < 0 {
+		info, err := os.Stat(src)
+		if err != nil {
+			return
+		}
+		size = info.Size()
+	}
+	layout.add(dst, size)
+}
+
+// add counts a file of size bytes at dst in its folder and all folders above it.
+func (l *destLayout) add(dst string, size int64) {
+	rel, err := filepath.Rel(l.root, filepath.Dir(dst))
+	if err != nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		d := l.dirs[rel]
+		if d == nil {
+			d = &layoutDir{}
+			l.dirs[rel] = d
+		}
+		d.files++
+		d.bytes += size
+		if rel == "." {
+			return
+		}
+		rel = filepath.Dir(rel)
+	}
+}
+
+// Print writes the folders as an indented tree with their file counts and sizes.
+func (l *destLayout) Print(w io.Writer) {
+	paths := make([][]string, 0, len(l.dirs))
+	for rel := range l.dirs {
+		if rel != "." {
+			paths = append(paths, strings.Split(filepath.ToSlash(rel), "/"))
+		}
+	}
+	// By component, so that "2024/01" comes right after "2024" and not after "2024-extra"
+	slices.SortFunc(paths, slices.Compare)
+
+	fmt.Fprintf(w, "%8s  %10s  %s\n", "FILES", "SIZE", "FOLDER")
+	total := l.dirs["."]
+	if total == nil {
+		total = &layoutDir{}
+	}
+	fmt.Fprintf(w, "%8d  %10s  %s\n", total.files, formatBytes(total.bytes), l.root)
+	for _, p := range paths {
+		d := l.dirs[filepath.Join(p...)]
+		fmt.Fprintf(w, "%8d  %10s  %s%s/\n", d.files, formatBytes(d.bytes), strings.Repeat("  ", len(p)), p[len(p)-1])
+	}
+}
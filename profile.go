@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/levmv/exisort/exifdate"
+)
+
+// cameraProfile holds the settings of a camera from the --profiles file.
+type cameraProfile struct {
+	Name     string        // Model or make as written in the file
+	Format   string        // Replaces --format, "" to keep it
+	Shift    time.Duration // Clock correction, see --shift-time
+	HasShift bool
+	Dest     string // Folder inside the destination the camera's files go to
+}
+
+// profiles are the --profiles entries by lower case model or make.
+var profiles map[string]*cameraProfile
+
+// loadProfiles reads a --profiles file: a section per camera, named by its EXIF model or make, with settings below it.
+//
+//	[Canon EOS R5]
+//	format = {year}/{year}-{month}/R5_{filename}.{ext}
+//	shift = -1h
+//	dest = Canon
+func loadProfiles(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	profiles = make(map[string]*cameraProfile)
+	var p *cameraProfile
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			p = &cameraProfile{Name: name}
+			profiles[strings.ToLower(name)] = p
+			continue
+		}
+		if err := p.set(line); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// set applies a "key = value" line of the profile.
+func (p *cameraProfile) set(line string) error {
+	if p == nil {
+		return errors.New("setting outside of a [camera] section")
+	}
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return errors.New("must be key = value")
+	}
+	value = strings.TrimSpace(value)
+	switch strings.TrimSpace(key) {
+	case "format":
+		p.Format = value
+	case "shift":
+		shift, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		p.Shift, p.HasShift = shift, true
+	case "dest":
+		if !filepath.IsLocal(value) {
+			return fmt.Errorf("dest %q must be a folder inside the destination", value)
+		}
+		p.Dest = value
+	default:
+		return fmt.Errorf("unknown setting %q (want format, shift or dest)", strings.TrimSpace(key))
+	}
+	return nil
+}
+
+// profileFor finds the profile of the camera md comes from: by model, or else by make. It returns nil if there is none.
+func profileFor(md exifdate.Metadata) *cameraProfile {
+	for _, name := range []string{md.Model, md.Make} {
+		if p := profiles[strings.ToLower(strings.TrimSpace(name))]; p != nil && name != "" {
+			return p
+		}
+	}
+	return nil
+}
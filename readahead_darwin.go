@@ -0,0 +1,12 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// adviseSequential turns on read-ahead for f, which is read front to back.
+func adviseSequential(f *os.File) {
+	unix.FcntlInt(f.Fd(), unix.F_RDAHEAD, 1)
+}
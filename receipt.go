@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const runsDir = "runs"
+
+// Receipt lists what one import did with every file, so that a run can be audited or undone later.
+// It is written to <dest>/.exisort/runs/<start time>.json when the run ends, also if it was interrupted.
+type Receipt struct {
+	mu sync.Mutex
+
+	Started    time.Time      `json:"started"`
+	Finished   time.Time      `json:"finished"`
+	Source     string         `json:"source"`
+	Dest       string         `json:"dest"`
+	Tag        string         `json:"tag,omitempty"`
+	Imported   int64          `json:"imported"`
+	Duplicates int64          `json:"duplicates"`
+	Errors     int64          `json:"errors"`
+	Files      []ReceiptEntry `json:"files"`
+}
+
+type ReceiptEntry struct {
+	Status string `json:"status"` // copied, moved, linked, duplicate, skipped or error
+	Source string `json:"source"`
+	Dest   string `json:"dest,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+var receipt *Receipt
+
+// startReceipt begins the receipt of a run, unless nothing is written.
+func startReceipt(src, dst string) {
+	receipt = nil
+	if cfg.DryRun {
+		return
+	}
+	source := cfg.FilesFrom
+	if src != "" {
+		source = absPath(src)
+	}
+	receipt = &Receipt{Started: stats.StartTime, Source: source, Dest: absPath(dst), Tag: cfg.Tag, Files: []ReceiptEntry{}}
+}
+
+// Add records what happened to a file. err is only set for errors.
+func (r *Receipt) Add(status string, job FileJob, dest string, err error) {
+	if r == nil {
+		return
+	}
+	e := ReceiptEntry{Status: status, Source: absPath(job.Path)}
+	if dest != "" {
+		e.Dest = absPath(dest)
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	r.mu.Lock()
+	r.Files = append(r.Files, e)
+	r.mu.Unlock()
+}
+
+// Save writes the receipt with the totals of the run.
+func (r *Receipt) Save() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.Finished = time.Now()
+	r.Imported, r.Duplicates, r.Errors = stats.FilesProcessed.Load(), stats.Duplicates.Load(), stats.Errors.Load()
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(r.Dest, metaDir, runsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, r.Started.Format("20060102-150405")+".json"), data, 0644)
+}
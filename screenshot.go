@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/levmv/exisort/exifdate"
+)
+
+// screenshotPrefixes are how screenshots are named by phones and desktops, in several languages (lower case).
+var screenshotPrefixes = []string{
+	"screenshot", "screen shot", "screen_shot", "simulator screen shot", "bildschirmfoto", "capture d'écran",
+	"capture d’écran", "captura de pantalla", "schermata", "schermafbeelding", "skärmbild", "zrzut ekranu",
+	"снимок экрана", "スクリーンショット", "屏幕截图",
+}
+
+// screenshotSoftware are the programs that write their name into the Software tag of screenshots (lower case).
+var screenshotSoftware = []string{"screenshot", "greenshot", "sharex", "flameshot", "spectacle", "snipping tool", "lightshot"}
+
+// isScreenshot tells screenshots from photos by their name and metadata. PNGs named like camera files
+// (IMG_0001.PNG) without a camera in their EXIF are screenshots of older iPhones.
+func isScreenshot(path string, md exifdate.Metadata) bool {
+	if strings.EqualFold(md.UserComment, "Screenshot") {
+		return true
+	}
+	software := strings.ToLower(md.Software)
+	for _, s := range screenshotSoftware {
+		if strings.Contains(software, s) {
+			return true
+		}
+	}
+
+	name := strings.ToLower(filepath.Base(path))
+	for _, p := range screenshotPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return filepath.Ext(name) == ".png" && strings.HasPrefix(name, "img_") && md.Make == ""
+}
+
+// wantScreenshot is the --screenshots skip filter.
+func wantScreenshot(path string, md exifdate.Metadata) bool {
+	if cfg.Screenshots == "skip" && isScreenshot(path, md) {
+		log.Info("Skipping %s: screenshot", path)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// sidecarExts are files that describe a photo rather than being one:
+// XMP edits (Lightroom, darktable), Apple .AAE edit lists and the .THM thumbnails of camera videos.
+var sidecarExts = []string{"xmp", "aae", "thm"}
+
+func isSidecar(path string) bool {
+	return slices.Contains(sidecarExts, strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")))
+}
+
+// dirListings caches the names in each source directory, lower case mapped to the real name,
+// so finding sidecars costs one directory read instead of a stat per candidate.
+var dirListings = struct {
+	sync.Mutex
+	m map[string]map[string]string
+}{m: make(map[string]map[string]string)}
+
+// resetCaches forgets what previous runs learned about the source and destination, which may since have changed
+// (watch mode imports one card after another from the same mount point).
+func resetCaches() {
+	dirListings.Lock()
+	dirListings.m = make(map[string]map[string]string)
+	dirListings.Unlock()
+
+	contentIDs.Lock()
+	contentIDs.m = make(map[string]string)
+	contentIDs.Unlock()
+
+	destDirs.Lock()
+	destDirs.m = make(map[string]map[string]bool)
+	destDirs.Unlock()
+
+	counters.Lock()
+	counters.m = make(map[string]int)
+	counters.Unlock()
+}
+
+func listDir(dir string) map[string]string {
+	dirListings.Lock()
+	defer dirListings.Unlock()
+
+	if names, ok := dirListings.m[dir]; ok {
+		return names
+	}
+	names := make(map[string]string)
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if !e.IsDir() {
+			names[strings.ToLower(e.Name())] = e.Name()
+		}
+	}
+	dirListings.m[dir] = names
+	return names
+}
+
+// findSidecars returns the sidecars next to path, both "IMG_0001.CR2.xmp" and "IMG_0001.xmp" style.
+func findSidecars(path string) []string {
+	dir, file := filepath.Split(path)
+	names := listDir(filepath.Clean(dir))
+	file = strings.ToLower(file)
+	base := strings.TrimSuffix(file, filepath.Ext(file))
+
+	var found []string
+	for _, stem := range []string{file, base} {
+		for _, ext := range sidecarExts {
+			if name, ok := names[stem+"."+ext]; ok {
+				found = append(found, filepath.Join(dir, name))
+			}
+		}
+	}
+	return found
+}
+
+// sidecarDest names a sidecar after the imported file, keeping its style:
+// "IMG_0001.CR2.xmp" becomes "<new name>.CR2.xmp", "IMG_0001.xmp" becomes "<new base>.xmp".
+func sidecarDest(primary, sidecar, destPath string) string {
+	name := filepath.Base(sidecar)
+	if prefix := filepath.Base(primary) + "."; len(name) > len(prefix) && strings.EqualFold(name[:len(prefix)], prefix) {
+		return destPath + name[len(prefix)-1:]
+	}
+	return strings.TrimSuffix(destPath, filepath.Ext(destPath)) + filepath.Ext(name)
+}
+
+// transferSidecars copies, moves or links the sidecars of an imported file next to it.
+// A sidecar that already exists at the destination is left alone.
+func transferSidecars(job FileJob, destPath string) {
+	for _, sc := range job.Sidecars {
+		dst := sidecarDest(job.Path, sc, destPath)
+		if cfg.DryRun {
+			dryTransfer(sc, dst, -1)
+			continue
+		}
+		info, err := os.Stat(sc)
+		if err != nil {
+			continue // Moved along with another file of the same base name
+		}
+		if existing, err := os.Stat(dst); err == nil {
+			// RAW+JPEG pairs share "IMG_0001.xmp", so the first of them usually brought it already
+			if same, _ := areFilesDeepIdentical(sc, dst, info.Size()); !same || existing.Size() != info.Size() {
+				log.Warn("Sidecar %s already exists, keeping it", dst)
+			} else if cfg.Move {
+				os.Remove(sc)
+			}
+			continue
+		}
+		if err := placeCompanion(sc, dst, info); err != nil {
+			stats.IncError()
+			log.Error("Failed to transfer sidecar %s: %v", sc, err)
+			continue
+		}
+		log.Info("Sidecar %s -> %s", sc, dst)
+	}
+}
+
+// placeCompanion copies, moves or links a file that travels with an imported one.
+func placeCompanion(src, dst string, info os.FileInfo) (err error) {
+	defer func() {
+		if err == nil {
+			noteWritten(dst)
+		}
+	}()
+	switch {
+	case cfg.Symlink:
+		target, err := filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dst)
+	case cfg.Move:
+		if err := os.Rename(src, dst); err == nil {
+			return nil
+		}
+		if err := copyFile(src, dst, info, nil); err != nil {
+			return err
+		}
+		return os.Remove(src)
+	default:
+		return copyFile(src, dst, info, nil)
+	}
+}
@@ -23,6 +23,9 @@ func InitStats() {
 	stats = &Statistics{
 		StartTime: time.Now(),
 	}
+	if cfg.Dest2 != "" {
+		mirrorStats = &Statistics{StartTime: stats.StartTime}
+	}
 }
 
 func (s *Statistics) IncScanned() {
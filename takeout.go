@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// takeoutMeta is the part of a Google Takeout sidecar we care about.
+type takeoutMeta struct {
+	PhotoTakenTime struct {
+		Timestamp string `json:"timestamp"`
+	} `json:"photoTakenTime"`
+}
+
+// findTakeoutSidecar returns the Google Takeout JSON file that belongs to path, or "".
+// Takeout names it "IMG_0001.jpg.json", newer exports use "IMG_0001.jpg.supplemental-metadata.json",
+// and duplicates "IMG_0001(1).jpg" get "IMG_0001.jpg(1).json". With shared, "IMG_0001.json" is tried
+// last; it may belong to IMG_0001.heic or IMG_0001.mp4 as well.
+func findTakeoutSidecar(path string, shared bool) string {
+	for _, c := range takeoutCandidates(path, shared) {
+		if _, err := os.Stat(c); err == nil {
+			return c
+		}
+	}
+	return ""
+}
+
+// takeoutCandidates lists the possible sidecar names of path, see findTakeoutSidecar.
+func takeoutCandidates(path string, shared bool) []string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+
+	candidates := []string{
+		path + ".json",
+		path + ".supplemental-metadata.json",
+	}
+	if i := strings.LastIndexByte(base, '('); i > 0 && strings.HasSuffix(base, ")") {
+		candidates = append(candidates, base[:i]+ext+base[i:]+".json")
+	}
+	if shared {
+		candidates = append(candidates, base+".json")
+	}
+	return candidates
+}
+
+// takeoutTime reads photoTakenTime from the Takeout sidecar of path.
+// It's a Unix timestamp, so the result is in local time.
+func takeoutTime(path string) (DateInfo, bool) {
+	sidecar := findTakeoutSidecar(path, true)
+	if sidecar == "" {
+		return DateInfo{}, false
+	}
+
+	data, err := os.ReadFile(sidecar)
+	if err != nil {
+		return DateInfo{}, false
+	}
+	return parseTakeout(data, filepath.Base(sidecar))
+}
+
+// parseTakeout reads photoTakenTime from the contents of the sidecar called name.
+func parseTakeout(data []byte, name string) (DateInfo, bool) {
+	var meta takeoutMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return DateInfo{}, false
+	}
+
+	raw := meta.PhotoTakenTime.Timestamp
+	ts, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || ts <= 0 {
+		return DateInfo{}, false
+	}
+	return DateInfo{Time: time.Unix(ts, 0), Source: "takeout", Tag: name, Raw: raw}, true
+}
+
+// cleanupTakeout deletes the Takeout sidecar of an imported file (--takeout-cleanup). One named after
+// the file without its extension is kept, as other files of that name may need it.
+func cleanupTakeout(job FileJob) {
+	if !cfg.TakeoutCleanup || cfg.DryRun || cfg.Symlink || job.FS != nil {
+		return
+	}
+	sidecar := findTakeoutSidecar(job.Path, false)
+	if sidecar == "" {
+		return
+	}
+	if err := os.Remove(sidecar); err != nil {
+		log.Error("Failed to delete sidecar %s: %v", sidecar, err)
+		return
+	}
+	log.Info("Deleted sidecar %s", sidecar)
+}
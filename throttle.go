@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// bwLimit is shared by all workers, so -bwlimit caps the total rate. nil means unlimited.
+var bwLimit *throttle
+
+// throttle paces reads to a fixed number of bytes per second.
+type throttle struct {
+	mu   sync.Mutex
+	rate float64   // Bytes per second
+	next time.Time // When the bytes read so far are paid off
+}
+
+func newThrottle(bytesPerSec int64) *throttle {
+	return &throttle{rate: float64(bytesPerSec)}
+}
+
+// wait blocks until reading n more bytes keeps within the rate.
+// Short delays are left to accumulate, so the worker isn't put to sleep after every chunk.
+func (t *throttle) wait(n int) {
+	if t == nil || n <= 0 {
+		return
+	}
+	t.mu.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(float64(n) / t.rate * float64(time.Second)))
+	delay := t.next.Sub(now)
+	t.mu.Unlock()
+
+	if delay > 20*time.Millisecond {
+		time.Sleep(delay)
+	}
+}
+
+type throttledReader struct {
+	r io.Reader
+}
+
+func (tr throttledReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	bwLimit.wait(n)
+	return n, err
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// trashDir is where clean puts the files it removes with -action trash, at the top of the target.
+// Its manifest.tsv records where each of them came from, for exisort restore.
+const trashDir = ".exisort-trash"
+
+var trashHeader = []string{"time", "name", "original"}
+
+// Trash moves files into the trash of a folder. It is created with the first file.
+type Trash struct {
+	root string
+	f    *os.File
+	w    *csv.Writer
+}
+
+// trashEntry is a line of the manifest: when a file was put in the trash, its name there
+// and its original path, relative to the trashed folder.
+type trashEntry struct {
+	time, name, original string
+}
+
+func newTrash(root string) *Trash {
+	return &Trash{root: root}
+}
+
+func (t *Trash) dir() string {
+	return filepath.Join(t.root, trashDir)
+}
+
+// Move puts path into the trash and records it in the manifest. Files of different folders may have the
+// same name, they become "IMG_0001.JPG", "IMG_0001_1.JPG"...
+func (t *Trash) Move(path string) error {
+	if t.f == nil {
+		if err := os.MkdirAll(t.dir(), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(filepath.Join(t.dir(), "manifest.tsv"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		t.f, t.w = f, csv.NewWriter(f)
+		t.w.Comma = '\t'
+		if info, err := f.Stat(); err == nil && info.Size() == 0 {
+			t.w.Write(trashHeader)
+		}
+	}
+
+	rel, err := filepath.Rel(t.root, path)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	ext := filepath.Ext(name)
+	dst := filepath.Join(t.dir(), name)
+	for n := 1; ; n++ {
+		if _, err := os.Lstat(dst); errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		dst = filepath.Join(t.dir(), strings.TrimSuffix(name, ext)+"_"+strconv.Itoa(n)+ext)
+	}
+	if err := os.Rename(path, dst); err != nil {
+		return err
+	}
+
+	// Flushed right away like the journal, so an interrupted clean loses no origin
+	t.w.Write([]string{time.Now().Format(time.RFC3339), filepath.Base(dst), filepath.ToSlash(rel)})
+	t.w.Flush()
+	if err := t.w.Error(); err != nil {
+		log.Warn("Failed to write the trash manifest: %v", err)
+	}
+	return nil
+}
+
+func (t *Trash) Close() error {
+	if t.f == nil {
+		return nil
+	}
+	return t.f.Close()
+}
+
+// entries lists the files of the manifest that are still in the trash. A name freed by a restore
+// can be taken again by a later clean; its last line is the one that counts.
+func (t *Trash) entries() ([]trashEntry, error) {
+	f, err := os.Open(filepath.Join(t.dir(), "manifest.tsv"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.Comma = '\t'
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	index := make(map[string]int)
+	var list []trashEntry
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil || len(rec) < len(trashHeader) || rec[0] == trashHeader[0] {
+			continue
+		}
+		e := trashEntry{time: rec[0], name: rec[1], original: filepath.FromSlash(rec[2])}
+		if i, ok := index[e.name]; ok {
+			list[i] = e
+			continue
+		}
+		index[e.name] = len(list)
+		list = append(list, e)
+	}
+
+	kept := list[:0]
+	for _, e := range list {
+		if _, err := os.Lstat(filepath.Join(t.dir(), e.name)); err == nil {
+			kept = append(kept, e)
+		}
+	}
+	return kept, nil
+}
+
+// rewrite replaces the manifest with the entries of the files left in the trash.
+func (t *Trash) rewrite(list []trashEntry) error {
+	path := filepath.Join(t.dir(), "manifest.tsv")
+	if len(list) == 0 {
+		return os.Remove(path)
+	}
+	f, err := os.Create(path + partSuffix)
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(f)
+	w.Comma = '\t'
+	w.Write(trashHeader)
+	for _, e := range list {
+		w.Write([]string{e.time, e.name, filepath.ToSlash(e.original)})
+	}
+	w.Flush()
+	err = w.Error()
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + partSuffix)
+		return err
+	}
+	return os.Rename(path+partSuffix, path)
+}
+
+// runRestore moves files that clean put in the trash of a folder back where they were: all of them,
+// those matching a pattern, or those confirmed one by one.
+//
+//	exisort restore <target> [-match <pattern>] [-i]
+func runRestore(args []string) error {
+	var match string
+	var ask bool
+	fset := flag.NewFlagSet("restore", flag.ExitOnError)
+	fset.StringVar(&match, "match", "", "Only restore files whose original path or name matches this pattern, e.g. \"*.MOV\" or \"2019/*\"")
+	fset.BoolVar(&ask, "i", false, "Ask before restoring each file")
+
+	// The flags may come before or after the target
+	fset.Parse(args)
+	var target string
+	if fset.NArg() > 0 {
+		target = fset.Arg(0)
+		fset.Parse(fset.Args()[1:])
+	}
+	if target == "" || fset.NArg() > 0 {
+		return errors.New("usage: exisort restore <target> [-match <pattern>] [-i]")
+	}
+	if _, err := filepath.Match(match, ""); err != nil {
+		return fmt.Errorf("-match: %w", err)
+	}
+
+	t := newTrash(target)
+	list, err := t.entries()
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s has no trash to restore from", target)
+	}
+	if err != nil {
+		return err
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	var left []trashEntry
+	var restored, errs int
+	for i, e := range list {
+		if !matchOriginal(match, e.original) {
+			left = append(left, e)
+			continue
+		}
+		if ask {
+			fmt.Fprintf(os.Stderr, "Restore %s? [y/N/q] ", e.original)
+			answer, err := in.ReadString('\n')
+			answer = strings.ToLower(strings.TrimSpace(answer))
+			if answer == "q" || (err != nil && answer == "") {
+				left = append(left, list[i:]...)
+				break
+			}
+			if answer != "y" && answer != "yes" {
+				left = append(left, e)
+				continue
+			}
+		}
+
+		src := filepath.Join(t.dir(), e.name)
+		dst := filepath.Join(target, e.original)
+		// An edited or damaged manifest must not move files from or to anywhere else
+		if filepath.Base(e.name) != e.name || e.name == ".." || filepath.IsAbs(e.original) || filepath.VolumeName(e.original) != "" ||
+			!isWithin(target, dst) || isWithin(t.dir(), dst) {
+			log.Error("Not restoring %q to %q: outside %s", e.name, e.original, target)
+			errs++
+			left = append(left, e)
+			continue
+		}
+		if !cfg.DryRun {
+			if err := restoreFile(src, dst); err != nil {
+				log.Error("Failed to restore %s: %v", dst, err)
+				errs++
+				left = append(left, e)
+				continue
+			}
+		}
+		log.Restore(src, dst)
+		restored++
+	}
+
+	if !cfg.DryRun && restored > 0 {
+		if err := t.rewrite(left); err != nil {
+			return fmt.Errorf("failed to update the trash manifest: %w", err)
+		}
+		if len(left) == 0 {
+			os.Remove(t.dir()) // Only if nothing else is in it
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Restored %d files, %d left in the trash\n", restored, len(left))
+	if errs > 0 {
+		return fmt.Errorf("%d files could not be restored", errs)
+	}
+	return nil
+}
+
+// matchOriginal reports whether the original path of a trashed file, or its name, matches pattern.
+func matchOriginal(pattern, original string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, original)
+	if !ok {
+		ok, _ = filepath.Match(pattern, filepath.Base(original))
+	}
+	return ok
+}
+
+// restoreFile moves a file out of the trash to dst, recreating its folder. A file that took its place meanwhile
+// is left alone.
+func restoreFile(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return errors.New("a file with that name exists")
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return os.Rename(src, dst)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// videoZoneReach is how far in time the photo that lends its zone to a video (--video-tz auto) may be.
+const videoZoneReach = 12 * time.Hour
+
+// isUTCVideo reports whether t, the date of the file name, is a video creation time kept in UTC
+// (QuickTime mvhd, Matroska DateUTC, the same tags read by ExifTool) rather than the local time of capture.
+func isUTCVideo(name string, t time.Time) bool {
+	return mediaType(name) == "video" && t.Location() == time.UTC
+}
+
+// localizeVideo moves a UTC video date into the zone given with --video-tz.
+func localizeVideo(name string, d *DateInfo) {
+	if cfg.VideoZone != nil && isUTCVideo(name, d.Time) {
+		d.Time = d.Time.In(cfg.VideoZone)
+	}
+}
+
+// inferVideoZones holds back all jobs until scanning is complete, then gives each UTC video the zone of the
+// photo taken closest to it that records its UTC offset (--video-tz auto).
+func inferVideoZones(in <-chan FileJob) <-chan FileJob {
+	out := make(chan FileJob, 100)
+	go func() {
+		defer close(out)
+		var all, zoned []FileJob
+		for job := range in {
+			all = append(all, job)
+			if loc := job.Date.Location(); job.Existing == "" && mediaType(job.Path) != "video" &&
+				job.DateSource != "mtime" && loc != time.Local && loc != time.UTC {
+				zoned = append(zoned, job)
+			}
+		}
+		slices.SortFunc(zoned, func(a, b FileJob) int { return a.Date.Compare(b.Date) })
+
+		for _, job := range all {
+			if job.Existing == "" && isUTCVideo(job.Path, job.Date) {
+				if photo, ok := closestInTime(zoned, job.Date); ok {
+					job.Date = job.Date.In(photo.Date.Location())
+					log.Info("Video %s: zone of %s", job.Path, photo.Path)
+				} else {
+					log.Info("Video %s: no photo with a zone around %s, keeping UTC", job.Path, job.Date.Format(time.DateTime))
+				}
+			}
+			out <- job
+		}
+	}()
+	return out
+}
+
+// closestInTime returns the job of sorted taken closest to t, if within videoZoneReach.
+func closestInTime(sorted []FileJob, t time.Time) (FileJob, bool) {
+	i, _ := slices.BinarySearchFunc(sorted, t, func(j FileJob, t time.Time) int { return j.Date.Compare(t) })
+	var best FileJob
+	bestDist := videoZoneReach + 1
+	for _, k := range []int{i - 1, i} {
+		if k < 0 || k >= len(sorted) {
+			continue
+		}
+		if d := sorted[k].Date.Sub(t).Abs(); d < bestDist {
+			best, bestDist = sorted[k], d
+		}
+	}
+	return best, bestDist <= videoZoneReach
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// watchInterval is how often the mounted volumes are checked in watch mode.
+const watchInterval = 2 * time.Second
+
+// runWatch waits for memory cards and imports the DCIM folder of each one as soon as it is mounted,
+// printing a summary per card, until interrupted. Volumes already mounted at the start are left alone;
+// a card is imported again after it has been removed and reinserted.
+func runWatch(ctx context.Context, metaSvc *MetadataService, dstRoot string) error {
+	mounts, err := listMounts()
+	if err != nil {
+		return err
+	}
+	seen := make(map[string]bool)
+	for _, m := range mounts {
+		seen[m] = true
+	}
+	log.Card("Waiting for memory cards (Ctrl-C to stop)")
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		mounts, err := listMounts()
+		if err != nil {
+			log.Warn("Failed to list volumes: %v", err)
+			continue
+		}
+		current := make(map[string]bool)
+		for _, m := range mounts {
+			current[m] = true
+			if seen[m] {
+				continue
+			}
+			seen[m] = true
+
+			dcim := filepath.Join(m, "DCIM")
+			if info, err := os.Stat(dcim); err != nil || !info.IsDir() {
+				log.Info("Ignoring %s: no DCIM folder", m)
+				continue
+			}
+			if err := importCard(ctx, metaSvc, dcim, dstRoot); err != nil {
+				return err
+			}
+		}
+		for m := range seen {
+			if !current[m] {
+				delete(seen, m) // Removed, import it again next time
+			}
+		}
+	}
+}
+
+// importCard runs one import session for a card.
+func importCard(ctx context.Context, metaSvc *MetadataService, src, dstRoot string) error {
+	log.Card("Importing %s", src)
+	InitStats()
+	startReceipt(src, dstRoot)
+	err := Run(ctx, metaSvc, src, dstRoot)
+	if err := receipt.Save(); err != nil {
+		log.Warn("Failed to write the receipt: %v", err)
+	}
+	log.ClearStatus()
+	log.Card("Done with %s", src)
+	stats.PrintSummary()
+	printMirrorSummary()
+	if err == nil {
+		journal.Clear()
+		runHook(cfg.RunHook, runSummaryVars(src, dstRoot))
+	}
+	return err
+}